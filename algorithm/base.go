@@ -1,13 +1,148 @@
 package algorithm
 
 import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
+	"time"
 )
 
 type Algorithm interface {
-	Select(activated set.Set) set.Set
+	// Select picks Config.Seeds seeds outside activated. Once Config.TimeLimit seconds have passed it
+	// returns the best seed set found so far, and Truncated reports true if the algorithm implements
+	// Truncatable. If ctx is done it returns ctx.Err() together with the seeds found so far.
+	Select(ctx context.Context, activated set.Set) (set.Set, error)
+}
+
+// Certified is implemented by algorithms that can bound the approximation ratio of their last selection.
+type Certified interface {
+	Approximation() float64
+}
+
+// Truncatable is implemented by algorithms that can stop early on a time or memory budget.
+type Truncatable interface {
+	// Truncated reports whether the last selection was cut short by Config.TimeLimit or, when
+	// Config.MemoryPolicy allows it, by Config.MemoryBudget.
+	Truncated() bool
+}
+
+// Greedy is implemented by algorithms that pick seeds one at a time and never revise a pick, so that
+// the first k seeds of a selection are also their selection of k seeds.
+type Greedy interface {
+	// Order returns the seeds of the last selection in the order they were picked.
+	Order() []util.Node
+}
+
+// greedy records the seeds of a selection in the order they are picked, see Greedy.
+type greedy struct {
+	order []util.Node
+}
+
+// pick adds u to the seeds s, recording it unless it already is a seed.
+func (g *greedy) pick(s set.Set, u util.Node) {
+	if s.Add(u) {
+		g.order = append(g.order, u)
+	}
+}
+
+func (g *greedy) Order() []util.Node {
+	return g.order
 }
 
 type base struct {
 	Incremental bool
+	ctx         context.Context
+	deadline    time.Time
+	truncated   bool
+	aborted     bool
+	abortErr    error
+}
+
+// begin starts the budget of a selection, which ends when ctx is done or after Config.TimeLimit seconds.
+func (b *base) begin(ctx context.Context, config *util.Config) {
+	b.ctx = ctx
+	b.truncated = false
+	b.aborted = false
+	b.abortErr = nil
+	b.deadline = time.Time{}
+	if config.TimeLimit > 0 {
+		b.deadline = time.Now().Add(time.Duration(config.TimeLimit * float64(time.Second)))
+	}
+}
+
+// expired reports whether the budget is over, it is safe to call from several goroutines.
+func (b *base) expired() bool {
+	return b.ctx.Err() != nil || (!b.deadline.IsZero() && time.Now().After(b.deadline))
+}
+
+// stopped reports whether the selection must stop, marking it truncated when the time limit expired.
+func (b *base) stopped() bool {
+	if b.aborted || b.ctx.Err() != nil {
+		return true
+	}
+	if b.expired() {
+		b.truncated = true
+		return true
+	}
+
+	return false
+}
+
+// budget returns a context done when the budget of the selection is over, to be cancelled once used.
+func (b *base) budget() (context.Context, context.CancelFunc) {
+	if b.deadline.IsZero() {
+		return context.WithCancel(b.ctx)
+	}
+
+	return context.WithDeadline(b.ctx, b.deadline)
+}
+
+// sample estimates the spread of seeds with est, cut short when the budget ends during the
+// simulations if est is a model.ContextEstimator. The estimate is then partial, stopped reports true.
+func (b *base) sample(est model.SpreadEstimator, activated, seeds set.Set) util.Spread {
+	ce, ok := est.(model.ContextEstimator)
+	if !ok {
+		return est.Sample(activated, seeds)
+	}
+
+	ctx, cancel := b.budget()
+	defer cancel()
+	spread, _ := ce.SampleContext(ctx, activated, seeds)
+	return spread
+}
+
+// sampleWith is sample for model.SpreadEstimator.SampleWith.
+func (b *base) sampleWith(est model.SpreadEstimator, activated, seeds set.Set, extra util.Node) (float64, float64) {
+	ce, ok := est.(model.ContextEstimator)
+	if !ok {
+		return est.SampleWith(activated, seeds, extra)
+	}
+
+	ctx, cancel := b.budget()
+	defer cancel()
+	spread, withExtra, _ := ce.SampleWithContext(ctx, activated, seeds, extra)
+	return spread, withExtra
+}
+
+// abort stops the selection with err, or marks it truncated if err is nil.
+func (b *base) abort(err error) {
+	b.aborted = true
+	b.abortErr = err
+	if err == nil {
+		b.truncated = true
+	}
+}
+
+// stopErr is the error returned by a stopped selection, nil when it was truncated.
+func (b *base) stopErr() error {
+	if b.abortErr != nil {
+		return b.abortErr
+	}
+
+	return b.ctx.Err()
+}
+
+func (b *base) Truncated() bool {
+	return b.truncated
 }
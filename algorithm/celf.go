@@ -1,6 +1,7 @@
 package algorithm
 
 import (
+	"context"
 	"github.com/jtejido/goim/model"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
@@ -17,16 +18,46 @@ type celfNode struct {
 
 type CELF struct {
 	base
+	greedy
 	covQueue *util.PriorityQueue
 	graph    *util.Graph
 	config   *util.Config
-	ic       *model.IndependentCascade
+	sampler  model.SpreadEstimator
+	err      error // of building sampler, returned by Select
+}
+
+func init() {
+	Register(Registration{
+		Name:        "celf",
+		Description: "Greedy hill-climbing with lazy forward evaluation of Monte Carlo marginal gains (Leskovec et al. 2007)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewCELF(graph, config, t), nil
+		},
+		Options: monteCarloOptions,
+	})
 }
 
 func NewCELF(graph *util.Graph, config *util.Config, t int) *CELF {
 	c := new(CELF)
 	c.graph = graph
 	c.config = config
+	c.sampler, c.err = newSpreadEstimator(graph, config, t)
+	return c
+}
+
+// newSpreadEstimator returns the Monte Carlo spread estimator of the selection model.
+func newSpreadEstimator(graph *util.Graph, config *util.Config, t int) (model.SpreadEstimator, error) {
+	return model.New(config.SelectionModelName(), graph, config, t)
+}
+
+func (c *CELF) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.begin(ctx, c.config)
+	c.order = nil
+	// A fresh queue, the last selection may have left nodes in it.
 	c.covQueue = util.NewPriorityQueue(func(n1, n2 interface{}) bool {
 		if n1.(*celfNode).mg != n2.(*celfNode).mg {
 			return n2.(*celfNode).mg < n1.(*celfNode).mg // we want sorting in DESC order
@@ -34,29 +65,34 @@ func NewCELF(graph *util.Graph, config *util.Config, t int) *CELF {
 
 		return n1.(*celfNode).id < n2.(*celfNode).id
 	})
-
-	c.ic = model.NewIndependentCascade(graph, config, t)
-	return c
-}
-
-func (c *CELF) Select(activated set.Set) set.Set {
-
 	s := set.NewSet()
 
-	for node := range c.graph.Nodes().Iter() {
+	for node := 0; node < c.graph.NumNodes(); node++ {
+		if c.stopped() {
+			return c.fill(s), c.stopErr()
+		}
+
 		u := new(celfNode)
-		u.id = node.(util.Node)
+		u.id = util.Node(node)
 		seeds := set.NewSet()
-		seeds.Add(node.(util.Node))
-		u.mg = c.ic.Sample(activated, seeds)
+		seeds.Add(u.id)
+		u.mg = c.sample(c.sampler, activated, seeds).Mean
+		if c.stopped() {
+			return c.fill(s), c.stopErr()
+		}
 		c.covQueue.Push(u)
 	}
 
-	s.Add(c.covQueue.Peek().(*celfNode).id)
+	spread := c.covQueue.Peek().(*celfNode).mg
+	c.pick(s, c.covQueue.Peek().(*celfNode).id)
 	c.covQueue.Pop()
 	for s.Len() < c.config.Seeds {
 		var found bool
 		for !found {
+			if c.stopped() {
+				return c.fill(s), c.stopErr()
+			}
+
 			u := c.covQueue.Peek().(*celfNode)
 			c.covQueue.Pop()
 			seeds := set.NewSet()
@@ -65,10 +101,13 @@ func (c *CELF) Select(activated set.Set) set.Set {
 			}
 
 			seeds.Add(u.id)
-			prev_val := u.mg
-			u.mg = c.ic.Sample(activated, seeds) - prev_val
+			u.mg = c.sample(c.sampler, activated, seeds).Mean - spread
+			if c.stopped() {
+				return c.fill(s), c.stopErr()
+			}
 			if u.mg >= c.covQueue.Peek().(*celfNode).mg {
-				s.Add(u.id)
+				c.pick(s, u.id)
+				spread += u.mg
 				found = true
 			} else {
 				c.covQueue.Push(u)
@@ -76,5 +115,14 @@ func (c *CELF) Select(activated set.Set) set.Set {
 		}
 	}
 
+	return s, nil
+}
+
+// fill completes s, once the selection is stopped, with the nodes of highest last known marginal gain.
+func (c *CELF) fill(s set.Set) set.Set {
+	for s.Len() < c.config.Seeds && c.covQueue.Len() > 0 {
+		c.pick(s, c.covQueue.Pop().(*celfNode).id)
+	}
+
 	return s
 }
@@ -0,0 +1,86 @@
+package algorithm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hubGraph reads 4 hubs over overlapping ranges of leaves, so that the greedy order differs from the
+// order of single-node spreads and lazy evaluation skips some gains: hub 100 reaches leaves 0..19,
+// 101 leaves 10..27, 102 leaves 30..41 and 103 leaves 0..15. The in-weights of each leaf sum to at most
+// 0.9, so the graph also holds under LT.
+func hubGraph(t *testing.T) *util.Graph {
+	t.Helper()
+	var b strings.Builder
+	for hub, leaves := range map[int][2]int{100: {0, 20}, 101: {10, 28}, 102: {30, 42}, 103: {0, 16}} {
+		for v := leaves[0]; v < leaves[1]; v++ {
+			fmt.Fprintf(&b, "%d %d 0.3\n", hub, v)
+		}
+	}
+	g, err := util.ReadGraph(strings.NewReader(b.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestCELFPPMatchesCELF(t *testing.T) {
+	g := hubGraph(t)
+	for _, model := range []string{"ic", "lt"} {
+		config := &util.Config{Seeds: 3, Model: model, Seed: 7, Simulations: 2000}
+		var selected [2]set.Set
+		for i, name := range []string{"celf", "celfpp"} {
+			a, err := New(name, g, config, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if selected[i], err = a.Select(context.Background(), set.NewSet()); err != nil || selected[i].Len() != 3 {
+				t.Fatalf("%s under %s: selected %v, %v", name, model, selected[i], err)
+			}
+		}
+		if !selected[0].Equal(selected[1]) {
+			t.Errorf("under %s: CELF++ selected %v, CELF %v", model, selected[1], selected[0])
+		}
+	}
+}
+
+func TestCELFStopsWithinSimulations(t *testing.T) {
+	g := testGraph(t)
+	for _, name := range []string{"celf", "celfpp"} {
+		for _, model := range []string{"ic", "lt"} {
+			// A single estimate of 2^24 simulations takes far longer than the test allows.
+			config := &util.Config{Seeds: 3, Model: model, Seed: 1, Simulations: 1 << 24}
+			a, err := New(name, g, config, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			t0 := time.Now()
+			if _, err := a.Select(ctx, set.NewSet()); !errors.Is(err, context.Canceled) {
+				t.Errorf("%s under %s: cancelled selection returned %v", name, model, err)
+			}
+			if d := time.Since(t0); d > 5*time.Second {
+				t.Errorf("%s under %s: cancelled selection returned after %v", name, model, d)
+			}
+
+			config.TimeLimit = 0.05
+			t0 = time.Now()
+			seeds, err := a.Select(context.Background(), set.NewSet())
+			if err != nil || seeds.Len() > 3 || !a.(Truncatable).Truncated() {
+				t.Errorf("%s under %s: time-limited selection returned %v, %v", name, model, seeds, err)
+			}
+			if d := time.Since(t0); d > 5*time.Second {
+				t.Errorf("%s under %s: time-limited selection returned after %v", name, model, d)
+			}
+		}
+	}
+}
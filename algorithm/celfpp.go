@@ -0,0 +1,151 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+)
+
+type celfppNode struct {
+	Node
+	mg1      float64     // marginal gain w.r.t. the current seed set
+	mg2      float64     // marginal gain w.r.t. the current seed set and prevBest
+	prevBest *celfppNode // best node seen in the iteration mg2 was computed
+	flag     int         // seed set size when mg1 was last computed
+}
+
+// A. Goyal, W. Lu, L. Lakshmanan. CELF++: Optimizing the Greedy Algorithm for Influence Maximization
+// in Social Networks, WWW 2011.
+// CELF++ computes the gain of a node w.r.t. the best node of the current iteration in the same
+// Monte Carlo pass, so that the node needs no re-evaluation if that best node is picked next.
+type CELFPP struct {
+	base
+	greedy
+	covQueue *util.PriorityQueue
+	graph    *util.Graph
+	config   *util.Config
+	sampler  model.SpreadEstimator
+	err      error // of building sampler, returned by Select
+}
+
+func init() {
+	Register(Registration{
+		Name:        "celfpp",
+		Description: "CELF computing the next iteration's gain in the same Monte Carlo pass (Goyal et al. 2011)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewCELFPP(graph, config, t), nil
+		},
+		Options: monteCarloOptions,
+	})
+}
+
+func NewCELFPP(graph *util.Graph, config *util.Config, t int) *CELFPP {
+	c := new(CELFPP)
+	c.graph = graph
+	c.config = config
+	c.sampler, c.err = newSpreadEstimator(graph, config, t)
+	return c
+}
+
+func (c *CELFPP) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.begin(ctx, c.config)
+	c.order = nil
+	// A fresh queue, the gains and best nodes left by the last selection are stale.
+	c.covQueue = util.NewPriorityQueue(func(n1, n2 interface{}) bool {
+		if n1.(*celfppNode).mg1 != n2.(*celfppNode).mg1 {
+			return n2.(*celfppNode).mg1 < n1.(*celfppNode).mg1 // we want sorting in DESC order
+		}
+
+		return n1.(*celfppNode).id < n2.(*celfppNode).id
+	})
+	s := set.NewSet()
+	var spread float64
+	var lastSeed, curBest *celfppNode
+
+	for node := 0; node < c.graph.NumNodes(); node++ {
+		if c.stopped() {
+			return c.fill(s), c.stopErr()
+		}
+
+		u := new(celfppNode)
+		u.id = util.Node(node)
+		c.evaluate(u, activated, s, spread, curBest)
+		if c.stopped() {
+			return c.fill(s), c.stopErr()
+		}
+		if curBest == nil || u.mg1 > curBest.mg1 {
+			curBest = u
+		}
+
+		c.covQueue.Push(u)
+	}
+
+	for s.Len() < c.config.Seeds && c.covQueue.Len() > 0 {
+		if c.stopped() {
+			return c.fill(s), c.stopErr()
+		}
+
+		u := c.covQueue.Pop().(*celfppNode)
+		if u.flag == s.Len() {
+			c.pick(s, u.id)
+			spread += u.mg1
+			lastSeed = u
+			curBest = nil
+			continue
+		}
+
+		if u.prevBest == lastSeed && u.flag == s.Len()-1 {
+			u.mg1 = u.mg2
+			u.flag = s.Len()
+		} else {
+			c.evaluate(u, activated, s, spread, curBest)
+			if c.stopped() {
+				return c.fill(s), c.stopErr()
+			}
+		}
+
+		if curBest == nil || u.mg1 > curBest.mg1 {
+			curBest = u
+		}
+
+		c.covQueue.Push(u)
+	}
+
+	return s, nil
+}
+
+// fill completes s, once the selection is stopped, with the nodes of highest last known marginal gain.
+func (c *CELFPP) fill(s set.Set) set.Set {
+	for s.Len() < c.config.Seeds && c.covQueue.Len() > 0 {
+		c.pick(s, c.covQueue.Pop().(*celfppNode).id)
+	}
+
+	return s
+}
+
+// evaluate recomputes both marginal gains of u w.r.t. the seed set s, whose spread is given, and curBest.
+// The gains are partial once the selection is stopped.
+func (c *CELFPP) evaluate(u *celfppNode, activated, s set.Set, spread float64, curBest *celfppNode) {
+	seeds := set.NewSet()
+	for node := range s.Iter() {
+		seeds.Add(node.(util.Node))
+	}
+
+	seeds.Add(u.id)
+	u.flag = s.Len()
+	u.prevBest = curBest
+	if curBest == nil {
+		u.mg1 = c.sample(c.sampler, activated, seeds).Mean - spread
+		u.mg2 = u.mg1
+		return
+	}
+
+	withU, withBoth := c.sampleWith(c.sampler, activated, seeds, curBest.id)
+	u.mg1 = withU - spread
+	u.mg2 = withBoth - (spread + curBest.mg1)
+}
@@ -1,6 +1,7 @@
 package algorithm
 
 import (
+	"context"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 )
@@ -12,11 +13,22 @@ type discountDegreeNode struct {
 
 type DiscountDegree struct {
 	base
+	greedy
 	covQueue *util.PriorityQueue
 	graph    *util.Graph
 	config   *util.Config
 }
 
+func init() {
+	Register(Registration{
+		Name:        "discountdegree",
+		Description: "Highest degree, discounting the degree of neighbors of picked seeds (Chen et al. 2009)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewDiscountDegree(graph, config, t), nil
+		},
+	})
+}
+
 func NewDiscountDegree(graph *util.Graph, config *util.Config, t int) *DiscountDegree {
 	dd := new(DiscountDegree)
 	dd.graph = graph
@@ -32,17 +44,19 @@ func NewDiscountDegree(graph *util.Graph, config *util.Config, t int) *DiscountD
 	return dd
 }
 
-func (dd *DiscountDegree) Select(activated set.Set) set.Set {
+func (dd *DiscountDegree) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	dd.begin(ctx, dd.config)
+	dd.order = nil
 	s := set.NewSet()
 	queue_nodes := make(map[util.Node]*util.Item)
-	for node := range dd.graph.Nodes().Iter() {
+	for node := 0; node < dd.graph.NumNodes(); node++ {
 		nstruct := new(discountDegreeNode)
-		nstruct.id = node.(util.Node)
+		nstruct.id = util.Node(node)
 		if !activated.Contains(nstruct.id) {
 			nstruct.deg = 1.
 		}
-		if dd.graph.Neighbors(node.(util.Node), false) != nil {
-			for _, edge := range dd.graph.Neighbors(node.(util.Node), false) {
+		if dd.graph.Neighbors(nstruct.id, false) != nil {
+			for _, edge := range dd.graph.Neighbors(nstruct.id, false) {
 				if !activated.Contains(edge.Target) {
 					nstruct.deg += edge.Dist
 				}
@@ -52,9 +66,9 @@ func (dd *DiscountDegree) Select(activated set.Set) set.Set {
 		queue_nodes[nstruct.id] = dd.covQueue.Push(nstruct)
 	}
 
-	for s.Len() < dd.config.Seeds {
+	for s.Len() < dd.config.Seeds && !dd.stopped() {
 		nstruct := dd.covQueue.Peek().(*discountDegreeNode)
-		s.Add(nstruct.id)
+		dd.pick(s, nstruct.id)
 		if dd.graph.Neighbors(nstruct.id, false) != nil {
 			for _, edge := range dd.graph.Neighbors(nstruct.id, false) {
 				if !activated.Contains(edge.Target) && !s.Contains(edge.Target) {
@@ -68,5 +82,5 @@ func (dd *DiscountDegree) Select(activated set.Set) set.Set {
 		dd.covQueue.Pop()
 	}
 
-	return s
+	return s, dd.stopErr()
 }
@@ -0,0 +1,133 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"math"
+)
+
+const (
+	default_epsilon = 0.1
+	default_ell     = 1.
+)
+
+// Y. Tang, Y. Shi, and X. Xiao. Influence Maximization in Near-Linear Time: A Martingale Approach, SIGMOD 2015.
+// IMM shares TIM's RR-set construction and greedy node selection, it only replaces the sampling phase
+// with a martingale-based lower bound on OPT.
+type IMM struct {
+	TIM
+	ell float64
+}
+
+func init() {
+	Register(Registration{
+		Name:        "imm",
+		Description: "Influence Maximization via Martingales on reverse-reachable sets (Tang et al. 2015)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewIMM(graph, config, t), nil
+		},
+		Options: append([]util.Option{
+			{Key: "epsilon", Description: "approximation error"},
+			{Key: "ell", Description: "failure exponent, success with probability at least 1-1/n^ell"},
+		}, rrSetOptions...),
+	})
+}
+
+func NewIMM(graph *util.Graph, config *util.Config, t int) *IMM {
+	c := new(IMM)
+	c.TIM = *NewTIM(graph, config, t)
+	c.epsilon = config.Epsilon
+	if c.epsilon <= 0 {
+		c.epsilon = default_epsilon
+	}
+
+	c.ell = config.Ell
+	if c.ell <= 0 {
+		c.ell = default_ell
+	}
+
+	return c
+}
+
+func (c *IMM) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.reset(activated)
+
+	sampler, err := newRRSampler(c.graph, c.config, c.t)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.selectWith(sampler, grand.New(c.src))
+}
+
+// selectWith runs both phases of IMM on the RR sets of sampler.
+func (c *IMM) selectWith(sampler model.RRSampler, dst *grand.Rand) (set.Set, error) {
+	c.buildSamples(0, sampler, dst)
+
+	n := float64(len(c.nodes))
+	// ℓ is increased so that the overall failure probability stays below 1/n^ℓ.
+	ell := c.ell * (1 + math.Log(2)/math.Log(n))
+	lb := c.sampling(n, ell, sampler, dst)
+	if c.stopped() {
+		return c.stop()
+	}
+
+	// Node selection draws θ fresh sets: the ones sampled while estimating OPT depend on where that
+	// phase stopped, and reusing them voids the martingale bound (W. Chen, An Issue in the Martingale
+	// Analysis of the IMM Algorithm, 2018).
+	theta := c.lambdaStar(n, ell) / lb
+	c.buildSamples(int(math.Ceil(theta)), sampler, dst)
+	return c.stop()
+}
+
+// sampling estimates a lower bound of OPT by statistical testing on a guess x that is halved each
+// iteration, extending the RR sets of the previous iteration.
+func (c *IMM) sampling(n, ell float64, sampler model.RRSampler, dst *grand.Rand) float64 {
+	lb := 1.
+	epsPrime := math.Sqrt(2) * c.epsilon
+	lambdaPrime := (2 + 2./3*epsPrime) * (logcnk(int(n), c.k) + ell*math.Log(n) + math.Log(math.Log2(n))) * n / (epsPrime * epsPrime)
+	for i := 1.; i < math.Log2(n); i++ {
+		x := n / math.Pow(2, i)
+		theta := lambdaPrime / x
+		c.growSamples(int(math.Ceil(theta)), sampler, dst)
+		if c.stopped() {
+			break
+		}
+
+		c.buildSeedSet()
+		if f := n * c.coverage(); f >= (1+epsPrime)*x {
+			lb = f / (1 + epsPrime)
+			break
+		}
+	}
+
+	return lb
+}
+
+func (c *IMM) lambdaStar(n, ell float64) float64 {
+	e := 1 - 1/math.E
+	alpha := math.Sqrt(ell*math.Log(n) + math.Log(2))
+	beta := math.Sqrt(e * (logcnk(int(n), c.k) + ell*math.Log(n) + math.Log(2)))
+	return 2 * n * math.Pow(e*alpha+beta, 2) / (c.epsilon * c.epsilon)
+}
+
+// logcnk returns the natural logarithm of the binomial coefficient C(n, k).
+func logcnk(n, k int) float64 {
+	if k > n-k {
+		k = n - k
+	}
+
+	var ans float64
+	for i := n - k + 1; i <= n; i++ {
+		ans += math.Log(float64(i))
+	}
+	for i := 1; i <= k; i++ {
+		ans -= math.Log(float64(i))
+	}
+
+	return ans
+}
@@ -0,0 +1,80 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
+	"reflect"
+	"testing"
+)
+
+// recordingSampler keeps a copy of every RR set it samples, in order.
+type recordingSampler struct {
+	model.RRSampler
+	sets [][]util.Node
+}
+
+func (r *recordingSampler) RRSet(root util.Node) []util.Node {
+	rr := r.RRSampler.RRSet(root)
+	r.sets = append(r.sets, sorted(rr))
+	return rr
+}
+
+func TestIMMFreshSets(t *testing.T) {
+	g := testGraph(t)
+	config := &util.Config{Seeds: 3, Model: "ic", Seed: 5, Epsilon: 0.5}
+	c := NewIMM(g, config, 0)
+	c.begin(context.Background(), config)
+	c.reset(set.NewSet())
+	sampler, err := newRRSampler(g, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recordingSampler{RRSampler: sampler}
+	if _, err := c.selectWith(r, grand.New(source64.NewXoShiRo256StarStar(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The collection selected on is made of the sets sampled after those of the sampling phase.
+	phase := len(r.sets) - c.rrSets.Len()
+	if phase <= 0 || c.totalR != len(r.sets) {
+		t.Fatalf("sampled %d sets, %d counted, for a collection of %d", len(r.sets), c.totalR, c.rrSets.Len())
+	}
+	for i := 0; i < c.rrSets.Len(); i++ {
+		if got := c.rrSets.set(i); !reflect.DeepEqual(append([]util.Node{}, got...), r.sets[phase+i]) && len(got)+len(r.sets[phase+i]) > 0 {
+			t.Fatalf("set %d of the collection is %v, want the fresh set %v", i, got, r.sets[phase+i])
+		}
+	}
+}
+
+func TestIMMSpread(t *testing.T) {
+	g := testGraph(t)
+	const epsilon = 0.5
+	config := &util.Config{Seeds: 3, Model: "ic", Seed: 5, Epsilon: epsilon, Simulations: 20000}
+	ic, err := model.New("ic", g, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spread := make(map[string]float64)
+	for _, name := range []string{"tim", "imm"} {
+		a, err := New(name, g, config, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seeds, err := a.Select(context.Background(), set.NewSet())
+		if err != nil || seeds.Len() != config.Seeds {
+			t.Fatalf("%s: %v, %v", name, seeds, err)
+		}
+		spread[name] = ic.Sample(set.NewSet(), seeds).Mean
+	}
+
+	if spread["imm"] < (1-epsilon)*spread["tim"] {
+		t.Errorf("IMM spread %v, TIM %v", spread["imm"], spread["tim"])
+	}
+}
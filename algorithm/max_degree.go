@@ -1,6 +1,7 @@
 package algorithm
 
 import (
+	"context"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 )
@@ -12,11 +13,22 @@ type maxDegreeNode struct {
 
 type MaxDegree struct {
 	base
+	greedy
 	covQueue *util.PriorityQueue
 	graph    *util.Graph
 	config   *util.Config
 }
 
+func init() {
+	Register(Registration{
+		Name:        "maxdegree",
+		Description: "Highest out-degree nodes",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewMaxDegree(graph, config, t), nil
+		},
+	})
+}
+
 func NewMaxDegree(graph *util.Graph, config *util.Config, t int) *MaxDegree {
 	md := new(MaxDegree)
 	md.graph = graph
@@ -32,23 +44,25 @@ func NewMaxDegree(graph *util.Graph, config *util.Config, t int) *MaxDegree {
 	return md
 }
 
-func (md *MaxDegree) Select(activated set.Set) set.Set {
+func (md *MaxDegree) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	md.begin(ctx, md.config)
+	md.order = nil
 	s := set.NewSet()
 	seeds := set.NewSet()
-	for node := range md.graph.Nodes().Iter() {
+	for node := 0; node < md.graph.NumNodes(); node++ {
 		nstruct := new(maxDegreeNode)
-		nstruct.id = node.(util.Node)
-		nstruct.deg = float64(len(md.graph.Neighbors(node.(util.Node), false)))
+		nstruct.id = util.Node(node)
+		nstruct.deg = float64(len(md.graph.Neighbors(nstruct.id, false)))
 		md.covQueue.Push(nstruct)
 	}
 
-	for s.Len() < md.config.Seeds {
+	for s.Len() < md.config.Seeds && !md.stopped() {
 		nstruct := md.covQueue.Peek().(*maxDegreeNode)
 		if !seeds.Contains(nstruct.id) {
-			s.Add(nstruct.id)
+			md.pick(s, nstruct.id)
 			seeds.Add(nstruct.id)
 		}
 		md.covQueue.Pop()
 	}
-	return s
+	return s, md.stopErr()
 }
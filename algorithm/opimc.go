@@ -0,0 +1,108 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"math"
+)
+
+// J. Tang, X. Tang, X. Xiao, J. Yuan. Online Processing Algorithms for Influence Maximization, SIGMOD 2018.
+// OPIM-C greedily selects seeds on one collection of RR sets and validates them on an independent
+// one, doubling both until the certified approximation ratio reaches 1-1/e-ε or the time limit expires.
+type OPIMC struct {
+	TIM
+	delta    float64
+	approx   float64
+	validate *rrSets
+}
+
+func init() {
+	Register(Registration{
+		Name:        "opimc",
+		Description: "Online processing with a certified approximation ratio (Tang et al. 2018)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewOPIMC(graph, config, t), nil
+		},
+		Options: append([]util.Option{
+			{Key: "epsilon", Description: "target approximation error"},
+			{Key: "delta", Description: "failure probability, 1/n when unset"},
+		}, rrSetOptions...),
+	})
+}
+
+func NewOPIMC(graph *util.Graph, config *util.Config, t int) *OPIMC {
+	c := new(OPIMC)
+	c.TIM = *NewTIM(graph, config, t)
+	c.epsilon = config.Epsilon
+	if c.epsilon <= 0 {
+		c.epsilon = default_epsilon
+	}
+
+	c.delta = config.Delta
+	return c
+}
+
+func (c *OPIMC) Approximation() float64 {
+	return c.approx
+}
+
+func (c *OPIMC) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.reset(activated)
+	c.approx = 0
+
+	sampler, err := newRRSampler(c.graph, c.config, c.t)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := grand.New(c.src)
+
+	n := float64(len(c.nodes))
+	k := float64(c.k)
+	delta := failureProbability(c.delta, n)
+
+	e := 1 - 1/math.E
+	thetaMax := 2 * n * math.Pow(e*math.Sqrt(math.Log(6/delta))+math.Sqrt(e*(logcnk(int(n), c.k)+math.Log(6/delta))), 2) / (c.epsilon * c.epsilon * k)
+	theta := thetaMax * c.epsilon * c.epsilon * k / n
+	iMax := math.Ceil(math.Log2(thetaMax / theta))
+	a := math.Log(3 * iMax / delta)
+
+	c.buildSamples(int(math.Ceil(theta)), sampler, dst)
+	c.validate = newRRSets(0, false)
+	c.aux = []*rrSets{c.validate}
+	c.extendValidation(c.rrSets.Len(), sampler, dst)
+	if c.stopped() {
+		return c.stop()
+	}
+
+	for i := 1.; ; i++ {
+		upper := c.buildSeedSet()
+		lower := c.validate.covered(c.seeds)
+
+		sigmaL := (math.Pow(math.Sqrt(float64(lower)+2*a/9)-math.Sqrt(a/2), 2) - a/18) * n / float64(c.validate.Len())
+		sigmaU := math.Pow(math.Sqrt(float64(upper)+a/2)+math.Sqrt(a/2), 2) * n / float64(c.rrSets.Len())
+		c.approx = math.Max(sigmaL/sigmaU, 0)
+		if c.approx >= e-c.epsilon || i >= iMax {
+			break
+		}
+
+		// Both collections are doubled, keeping the sets sampled so far.
+		c.growSamples(2*c.rrSets.Len(), sampler, dst)
+		c.extendValidation(c.rrSets.Len()-c.validate.Len(), sampler, dst)
+		// The seeds and their certificate are those of the last complete round.
+		if c.stopped() {
+			return c.seeds, c.stopErr()
+		}
+	}
+
+	return c.seeds, nil
+}
+
+// extendValidation adds R new RR sets to the validation collection.
+func (c *OPIMC) extendValidation(R int, sampler model.RRSampler, dst *grand.Rand) {
+	c.extend(c.validate, R, sampler, dst)
+}
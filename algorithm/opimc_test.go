@@ -0,0 +1,36 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"math"
+	"testing"
+)
+
+func TestOPIMCApproximation(t *testing.T) {
+	const epsilon = 0.3
+	config := &util.Config{Seeds: 3, Model: "ic", Seed: 3, Epsilon: epsilon}
+	c := NewOPIMC(testGraph(t), config, 0)
+	seeds, err := c.Select(context.Background(), set.NewSet())
+	if err != nil || seeds.Len() != 3 {
+		t.Fatalf("Select = %v, %v", seeds, err)
+	}
+	if c.Approximation() < 1-1/math.E-epsilon {
+		t.Errorf("certified ratio %v, want at least %v", c.Approximation(), 1-1/math.E-epsilon)
+	}
+}
+
+func TestOPIMCTruncated(t *testing.T) {
+	// The first round of RR sets fits in the budget, the second one does not.
+	const epsilon = 0.1
+	config := &util.Config{Seeds: 1, Model: "ic", Seed: 3, Epsilon: epsilon, MemoryBudget: 1, MemoryPolicy: "truncate"}
+	c := NewOPIMC(cycleGraph(t, 3000), config, 0)
+	seeds, err := c.Select(context.Background(), set.NewSet())
+	if err != nil || seeds.Len() != 1 || !c.Truncated() {
+		t.Fatalf("Select = %v, %v, truncated %t", seeds, err, c.Truncated())
+	}
+	if r := c.Approximation(); r <= 0 || r >= 1-1/math.E-epsilon {
+		t.Errorf("certified ratio %v of a truncated run, want it in (0, %v)", r, 1-1/math.E-epsilon)
+	}
+}
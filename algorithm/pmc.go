@@ -1,10 +1,12 @@
 package algorithm
 
 import (
+	"context"
+	"github.com/jtejido/goim/model"
 	"github.com/jtejido/goim/util"
-	"github.com/jtejido/grand"
-	"github.com/jtejido/grand/source64"
 	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
 	"sort"
 )
 
@@ -16,23 +18,48 @@ const (
 // https://github.com/todo314/pruned-monte-carlo
 type PMC struct {
 	base
-	graph      *util.Graph
-	config     *util.Config
-	es1, rs1   []util.Node
-	at_e, at_r []int
+	greedy
+	graph  *util.Graph
+	config *util.Config
+	r      int
+}
+
+func init() {
+	Register(Registration{
+		Name:        "pmc",
+		Description: "Pruned Monte Carlo on live-edge snapshots (Ohsaka et al. 2014)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewPMC(graph, config, t), nil
+		},
+		Options: []util.Option{
+			{Key: "selectionModel", Description: "diffusion model snapshots are sampled under"},
+			{Key: "snapshots", Description: "number of live-edge snapshots"},
+			{Key: "workers", Description: "goroutines building the snapshots"},
+			{Key: "timeLimit", Description: "time budget in seconds"},
+		},
+	})
 }
 
 func NewPMC(graph *util.Graph, config *util.Config, t int) *PMC {
 	c := new(PMC)
 	c.graph = graph
 	c.config = config
-	c.es1 = make([]util.Node, graph.NumEdges())
-	c.rs1 = make([]util.Node, graph.NumEdges())
-	c.at_e = make([]int, graph.Nodes().Len()+1)
-	c.at_r = make([]int, graph.Nodes().Len()+1)
+	c.r = config.Snapshots
+	if c.r <= 0 {
+		c.r = default_r
+	}
+
 	return c
 }
 
+// snapshot is a live-edge graph in CSR form, es1 holds the targets of each node's living edges and
+// rs1 the sources of its reversed ones.
+type snapshot struct {
+	n          int
+	es1, rs1   []util.Node
+	at_e, at_r []int
+}
+
 type pair struct {
 	x, y util.Node
 }
@@ -43,102 +70,211 @@ func (a pairs) Len() int           { return len(a) }
 func (a pairs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a pairs) Less(i, j int) bool { return a[i].x < a[j].x }
 
-func (c *PMC) Select(activated set.Set) set.Set {
+func (c *PMC) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.order = nil
 	seeds := set.NewSet()
-	infs := make([]*prunedEstimator, default_r)
-
-	for t := 0; t < default_r; t++ {
-		xs := grand.New(source64.NewXoShiRo256StarStar(int64(t) + c.config.Seed))
-		mp := 0               // Number of living edges
-		ps := make([]pair, 0) // List of reversed living edges
-		c.at_e = make([]int, c.graph.Nodes().Len()+1)
-		c.at_r = make([]int, c.graph.Nodes().Len()+1)
-		for i := 0; i < c.graph.Nodes().Len(); i++ {
-			if c.graph.Neighbors(util.Node(i), false) == nil || len(c.graph.Neighbors(util.Node(i), false)) == 0 {
-				continue
-			}
-			for _, edge := range c.graph.Neighbors(util.Node(i), false) {
-				if xs.Float64() < edge.Dist {
-					c.es1[mp] = edge.Target // Lists of activated nodes (targets)
-					mp++
-					c.at_e[int(edge.Src)+1]++
-					ps = append(ps, pair{edge.Target, edge.Src})
-				}
-			}
-		}
-		c.at_e[0] = 0
-		sort.Sort(pairs(ps))
+	infs := make([]*prunedEstimator, c.r)
+	workers := c.config.NumWorkers()
+	if workers > c.r {
+		workers = c.r
+	}
 
-		for i := 0; i < mp; i++ {
-			c.rs1[util.Node(i)] = ps[i].y
-			c.at_r[int(ps[i].x)+1]++
-		}
-		for i := 1; i <= c.graph.Nodes().Len(); i++ {
-			c.at_e[i] += c.at_e[i-1]
-			c.at_r[i] += c.at_r[i-1]
+	// Each snapshot is seeded with its own index, so the estimators do not depend on which worker built them.
+	util.Parallel(workers, c.r, func(_, t int) {
+		if !c.expired() {
+			infs[t] = c.estimator(t, activated)
 		}
-		/**
-		  Here at_e is the sum of the number of activations from each node.
-		  Ex. [0, 2, 5, 8] if node 0 activated two edges, 1 three edges and 2 three
-		  edges.
-		  Similar thing for at_r;
-		*/
-		comp := make([]int, c.graph.Nodes().Len())
-
-		nscc := c.scc(comp)
-
-		es2 := set.NewSet() // List of edges in SCC graph, maintains unique pairs
-		for u := 0; u < c.graph.Nodes().Len(); u++ {
-			a := comp[u]
-			for i := c.at_e[u]; i < c.at_e[u+1]; i++ {
-				b := comp[int(c.es1[i])]
-				if a != b {
-					es2.Add(pair{util.Node(a), util.Node(b)})
-				}
+	})
+	if c.stopped() {
+		// The seeds are picked on the snapshots built in time, unless the selection was cancelled.
+		built := infs[:0]
+		for _, inf := range infs {
+			if inf != nil {
+				built = append(built, inf)
 			}
 		}
-
-		es2_b := make([]pair, es2.Len())
-		for i, item := range es2.ToSlice() {
-			es2_b[i] = item.(pair)
+		if infs = built; len(infs) == 0 || c.stopErr() != nil {
+			return seeds, c.stopErr()
+		}
+		if workers > len(infs) {
+			workers = len(infs)
 		}
-
-		sort.Sort(pairs(es2_b))
-
-		infs[t] = newPrunedEstimator(nscc, es2_b, comp, activated)
 	}
 
-	gain := make([]int64, c.graph.Nodes().Len())
+	// Every worker keeps its own gains over a fixed range of estimators, they are summed before each pick.
+	gains := make([][]int64, workers)
+	for w := range gains {
+		gains[w] = make([]int64, c.graph.NumNodes())
+	}
+	gain := make([]int64, c.graph.NumNodes())
 	S := make([]int, 0)
 
 	// Selects greedily seeds
 	for t := 0; t < c.config.Seeds; t++ {
-		for j := 0; j < default_r; j++ {
-			infs[j].update(gain)
+		c.parallel(workers, len(infs), func(w, j int) {
+			infs[j].update(gains[w])
+		})
+		for i := range gain {
+			gain[i] = 0
+			for w := range gains {
+				gain[i] += gains[w][i]
+			}
+		}
+		if c.stopped() {
+			// The remaining seeds are the nodes of highest marginal gain w.r.t. the seeds picked so far.
+			for _, u := range topGains(gain, c.config.Seeds-t, seeds) {
+				c.pick(seeds, util.Node(u))
+			}
+			break
 		}
+
 		next := 0
-		for i := 0; i < c.graph.Nodes().Len(); i++ {
+		for i := 0; i < c.graph.NumNodes(); i++ {
 			if gain[i] > gain[next] {
 				next = i
 			}
 		}
 
 		S = append(S, next)
-		for j := 0; j < default_r; j++ {
+		c.parallel(workers, len(infs), func(w, j int) {
 			infs[j].add(next)
+		})
+		c.pick(seeds, util.Node(next))
+	}
+
+	return seeds, c.stopErr()
+}
+
+// topGains returns the k nodes outside seeds with the largest gains.
+func topGains(gain []int64, k int, seeds set.Set) []int {
+	nodes := make([]int, 0, len(gain))
+	for i := range gain {
+		if !seeds.Contains(util.Node(i)) {
+			nodes = append(nodes, i)
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool { return gain[nodes[i]] > gain[nodes[j]] })
+	if len(nodes) > k {
+		nodes = nodes[:k]
+	}
+
+	return nodes
+}
+
+// parallel splits the r estimators into contiguous ranges, one per worker, and calls f on each of them.
+func (c *PMC) parallel(workers, r int, f func(w, j int)) {
+	util.Parallel(workers, workers, func(_, w int) {
+		for j := w * r / workers; j < (w+1)*r/workers; j++ {
+			f(w, j)
+		}
+	})
+}
+
+// estimator samples the t-th live-edge snapshot and builds the pruned estimator of its SCC condensation.
+func (c *PMC) estimator(t int, activated set.Set) *prunedEstimator {
+	src := source64.NewXoShiRo256StarStar(int64(t) + c.config.Seed)
+	var live []pair
+	if c.config.SelectionModelName() == model.LT {
+		live = c.liveEdgesLT(src)
+	} else {
+		live = c.liveEdgesIC(grand.New(src))
+	}
+
+	n := c.graph.NumNodes()
+	mp := len(live)           // Number of living edges
+	ps := make([]pair, 0, mp) // List of reversed living edges
+	g := &snapshot{
+		n:    n,
+		es1:  make([]util.Node, mp),
+		rs1:  make([]util.Node, mp),
+		at_e: make([]int, n+1),
+		at_r: make([]int, n+1),
+	}
+	for i, edge := range live {
+		g.es1[i] = edge.y // Lists of activated nodes (targets)
+		g.at_e[int(edge.x)+1]++
+		ps = append(ps, pair{edge.y, edge.x})
+	}
+	g.at_e[0] = 0
+	sort.Sort(pairs(ps))
+
+	for i := 0; i < mp; i++ {
+		g.rs1[util.Node(i)] = ps[i].y
+		g.at_r[int(ps[i].x)+1]++
+	}
+	for i := 1; i <= n; i++ {
+		g.at_e[i] += g.at_e[i-1]
+		g.at_r[i] += g.at_r[i-1]
+	}
+	/**
+	  Here at_e is the sum of the number of activations from each node.
+	  Ex. [0, 2, 5, 8] if node 0 activated two edges, 1 three edges and 2 three
+	  edges.
+	  Similar thing for at_r;
+	*/
+	comp := make([]int, n)
+
+	nscc := g.scc(comp)
+
+	es2 := set.NewSet() // List of edges in SCC graph, maintains unique pairs
+	for u := 0; u < n; u++ {
+		a := comp[u]
+		for i := g.at_e[u]; i < g.at_e[u+1]; i++ {
+			b := comp[int(g.es1[i])]
+			if a != b {
+				es2.Add(pair{util.Node(a), util.Node(b)})
+			}
 		}
-		seeds.Add(util.Node(next))
 	}
 
-	return seeds
+	es2_b := make([]pair, es2.Len())
+	for i, item := range es2.ToSlice() {
+		es2_b[i] = item.(pair)
+	}
+
+	sort.Sort(pairs(es2_b))
+
+	return newPrunedEstimator(nscc, es2_b, comp, activated)
+}
+
+// liveEdgesIC keeps each edge with its probability. Edges are returned as (source, target) pairs grouped by source.
+func (c *PMC) liveEdgesIC(xs *grand.Rand) []pair {
+	live := make([]pair, 0)
+	for i := 0; i < c.graph.NumNodes(); i++ {
+		for _, edge := range c.graph.Neighbors(util.Node(i), false) {
+			if xs.Float64() < edge.Dist {
+				live = append(live, pair{edge.Src, edge.Target})
+			}
+		}
+	}
+
+	return live
+}
+
+// liveEdgesLT keeps at most one in-edge per node, sampled from the node's in-edge weights. Edges are
+// returned as (source, target) pairs grouped by source.
+func (c *PMC) liveEdgesLT(src grand.Source) []pair {
+	live := make([]pair, 0)
+	for i := 0; i < c.graph.NumNodes(); i++ {
+		index := c.graph.SampleLivingEdge(util.Node(i), src)
+		if index == -1 {
+			continue
+		}
+
+		live = append(live, pair{c.graph.Neighbors(util.Node(i), true)[index].Target, util.Node(i)})
+	}
+
+	sort.Stable(pairs(live))
+	return live
 }
 
-func (c *PMC) scc(comp []int) int {
-	vis := make([]bool, c.graph.Nodes().Len())
+func (g *snapshot) scc(comp []int) int {
+	vis := make([]bool, g.n)
 	S := util.NewStack()
 	lis := make([]util.Node, 0)
 	var k int
-	for i := 0; i < c.graph.Nodes().Len(); i++ {
+	for i := 0; i < g.n; i++ {
 		S.Push(pair{util.Node(i), util.Node(0)})
 	}
 
@@ -152,18 +288,18 @@ func (c *PMC) scc(comp []int) int {
 			}
 			vis[v] = true
 			S.Push(pair{v, 1})
-			for i := c.at_e[v]; i < c.at_e[v+1]; i++ {
-				u := c.es1[i]
+			for i := g.at_e[v]; i < g.at_e[v+1]; i++ {
+				u := g.es1[i]
 				S.Push(pair{util.Node(u), util.Node(0)})
 			}
 		} else {
 			lis = append(lis, v)
 		}
 	}
-	for i := 0; i < c.graph.Nodes().Len(); i++ {
+	for i := 0; i < g.n; i++ {
 		S.Push(pair{lis[i], util.Node(-1)})
 	}
-	vis = make([]bool, c.graph.Nodes().Len())
+	vis = make([]bool, g.n)
 	for S.Len() > 0 {
 		cp := S.Pop().(pair)
 		v := cp.x
@@ -181,8 +317,8 @@ func (c *PMC) scc(comp []int) int {
 		} else {
 			comp[v] = int(arg)
 		}
-		for i := c.at_r[v]; i < c.at_r[v+1]; i++ {
-			u := c.rs1[i]
+		for i := g.at_r[v]; i < g.at_r[v+1]; i++ {
+			u := g.rs1[i]
 			S.Push(pair{util.Node(u), util.Node(comp[v])})
 		}
 	}
@@ -0,0 +1,59 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand/source64"
+	"testing"
+)
+
+func TestPMCWorkers(t *testing.T) {
+	g := testGraph(t)
+	for _, model := range []string{"ic", "lt"} {
+		var selected [2]set.Set
+		for i, workers := range []int{1, 4} {
+			config := &util.Config{Seeds: 5, Model: model, Seed: 11, Snapshots: 64, Workers: workers}
+			seeds, err := NewPMC(g, config, 0).Select(context.Background(), set.NewSet())
+			if err != nil || seeds.Len() != config.Seeds {
+				t.Fatalf("%d workers under %s: selected %v, %v", workers, model, seeds, err)
+			}
+			selected[i] = seeds
+		}
+		if !selected[0].Equal(selected[1]) {
+			t.Errorf("under %s: 4 workers selected %v, 1 worker %v", model, selected[1], selected[0])
+		}
+	}
+}
+
+func TestPMCLiveEdgesLT(t *testing.T) {
+	// The leaves of hubGraph have up to 3 in-edges.
+	g := hubGraph(t)
+	c := NewPMC(g, &util.Config{Model: "lt"}, 0)
+	var live int
+	for i := int64(0); i < 200; i++ {
+		edges := c.liveEdgesLT(source64.NewXoShiRo256StarStar(i))
+		in := make(map[util.Node]bool)
+		for j, e := range edges {
+			if in[e.y] {
+				t.Fatalf("snapshot %d keeps several in-edges of node %s", i, g.Label(e.y))
+			}
+			in[e.y] = true
+			if j > 0 && edges[j-1].x > e.x {
+				t.Errorf("snapshot %d: edges not grouped by source", i)
+			}
+
+			found := false
+			for _, edge := range g.Neighbors(e.y, true) {
+				found = found || edge.Target == e.x
+			}
+			if !found {
+				t.Errorf("snapshot %d keeps %s -> %s, not an edge of the graph", i, g.Label(e.x), g.Label(e.y))
+			}
+		}
+		live += len(edges)
+	}
+	if live == 0 {
+		t.Error("no snapshot keeps an edge")
+	}
+}
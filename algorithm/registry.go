@@ -0,0 +1,108 @@
+package algorithm
+
+import (
+	"fmt"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Constructor builds a seed-selection algorithm on graph, t is the influence type given by the evaluator.
+type Constructor func(graph *util.Graph, config *util.Config, t int) (Algorithm, error)
+
+// Registration describes a seed-selection algorithm selectable by name with the algorithm config key.
+type Registration struct {
+	Name        string
+	Description string
+	New         Constructor
+	// Config keys the algorithm reads.
+	Options []util.Option
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register makes an algorithm available by its name, which is case-insensitive. It panics if the
+// name is empty, already taken or the constructor is nil, so it is meant to be called from init.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := strings.ToLower(r.Name)
+	if name == "" || r.New == nil {
+		panic("algorithm: Register needs a name and a constructor")
+	}
+	if _, dup := registry[name]; dup {
+		panic("algorithm: Register called twice for " + name)
+	}
+
+	r.Name = name
+	registry[name] = r
+}
+
+// Lookup returns the registration of the named algorithm.
+func Lookup(name string) (Registration, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return Registration{}, fmt.Errorf("unknown algorithm %q, registered: %s", name, strings.Join(names(), ", "))
+	}
+
+	return r, nil
+}
+
+// New builds the named algorithm on graph, after checking that the selection model is registered.
+func New(name string, graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+	r, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := model.Lookup(config.SelectionModelName()); err != nil {
+		return nil, err
+	}
+
+	return r.New(graph, config, t)
+}
+
+// Registered returns every registered algorithm sorted by name.
+func Registered() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ret := make([]Registration, 0, len(registry))
+	for _, name := range names() {
+		ret = append(ret, registry[name])
+	}
+
+	return ret
+}
+
+func names() []string {
+	ret := make([]string, 0, len(registry))
+	for name := range registry {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Common config keys of the built-in algorithms.
+var (
+	monteCarloOptions = []util.Option{
+		{Key: "selectionModel", Description: "diffusion model the spread is simulated under"},
+		{Key: "simulations", Description: "Monte Carlo simulations per spread estimate"},
+		{Key: "precision", Description: "target relative half-width of the 95% confidence interval"},
+		{Key: "maxSimulations", Description: "simulation cap when precision is set"},
+		{Key: "workers", Description: "goroutines running IC simulations"},
+		{Key: "timeLimit", Description: "time budget in seconds"},
+	}
+	rrSetOptions = []util.Option{
+		{Key: "selectionModel", Description: "diffusion model RR sets are sampled under"},
+		{Key: "timeLimit", Description: "time budget in seconds"},
+		{Key: "memoryBudget", Description: "memory in MiB the RR sets may use"},
+		{Key: "memoryPolicy", Description: "\"error\" or \"truncate\" when the RR sets exceed memoryBudget"},
+	}
+)
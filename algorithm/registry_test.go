@@ -0,0 +1,92 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"strings"
+	"testing"
+)
+
+// testGraph returns the circulant graph of 50 nodes in testdata, each with 3 out-edges of
+// probability 0.2.
+func testGraph(t *testing.T) *util.Graph {
+	t.Helper()
+	g, err := util.NewGraph("../testdata/circulant.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"celf", "CELFPP", "dssa", "imm", "MaxDegree", "opimc", "pmc", "ssa", "tim"} {
+		if r, err := Lookup(name); err != nil {
+			t.Errorf("Lookup(%q): %v", name, err)
+		} else if r.Name != strings.ToLower(name) {
+			t.Errorf("Lookup(%q).Name = %q", name, r.Name)
+		}
+	}
+
+	if _, err := Lookup("greedy"); err == nil || !strings.Contains(err.Error(), "unknown algorithm") {
+		t.Errorf("Lookup(greedy) = %v, want an unknown algorithm error", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	g := testGraph(t)
+	config := &util.Config{Seeds: 2, Model: "ic", Simulations: 10}
+	if a, err := New("greedy", g, config, 0); err == nil || a != nil {
+		t.Errorf("New(greedy) = %v, %v, want an error", a, err)
+	}
+
+	bad := *config
+	bad.SelectionModel = "sir"
+	if a, err := New("maxdegree", g, &bad, 0); err == nil || a != nil {
+		t.Errorf("New under model sir = %v, %v, want an error", a, err)
+	}
+
+	a, err := New("maxdegree", g, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeds, err := a.Select(context.Background(), set.NewSet())
+	if err != nil || seeds.Len() != 2 {
+		t.Errorf("Select = %v, %v, want 2 seeds", seeds, err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	newMaxDegree := func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+		return New("maxdegree", graph, config, t)
+	}
+	Register(Registration{Name: "Test-Algorithm", New: newMaxDegree})
+	if r, err := Lookup("TEST-ALGORITHM"); err != nil || r.Name != "test-algorithm" {
+		t.Errorf("Lookup(TEST-ALGORITHM) = %v, %v", r.Name, err)
+	}
+
+	prev := ""
+	for _, r := range Registered() {
+		if r.Name < prev {
+			t.Errorf("Registered() not sorted: %q after %q", r.Name, prev)
+		}
+		prev = r.Name
+	}
+
+	for _, r := range []Registration{
+		{Name: "test-algorithm", New: newMaxDegree},
+		{Name: "", New: newMaxDegree},
+		{Name: "no-constructor"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", r.Name)
+				}
+			}()
+			Register(r)
+		}()
+	}
+}
@@ -0,0 +1,331 @@
+package algorithm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// ErrMemoryBudget is returned when the RR sets an algorithm needs do not fit in Config.MemoryBudget.
+var ErrMemoryBudget = errors.New("RR sets exceed the memory budget")
+
+const (
+	// Bytes of bookkeeping per RR set and per indexed node besides their encoded contents.
+	rr_set_overhead  = 8
+	rr_node_overhead = 32
+
+	rr_file_magic   = "GOIMRR"
+	rr_file_version = 1
+)
+
+// rrSets is an append-only collection of RR sets in CSR form. Each set is sorted and stored as
+// varint-encoded gaps in one flat byte slice, offsets holds where each set starts, plus the end of
+// the last one. An indexed collection also keeps, per node, the ids of the sets containing it,
+// encoded the same way, so that the greedy selection can walk from nodes to sets.
+type rrSets struct {
+	data       []byte
+	offsets    []int
+	index      [][]byte // ids of the sets containing each node
+	indexBytes int
+	degree     []int // number of sets containing each node
+	last       []int // id of the last set indexed for each node
+	scratch    []util.Node
+}
+
+func newRRSets(n int, indexed bool) *rrSets {
+	s := &rrSets{offsets: []int{0}}
+	if indexed {
+		s.index = make([][]byte, n)
+		s.degree = make([]int, n)
+		s.last = make([]int, n)
+	}
+
+	return s
+}
+
+// Len returns the number of RR sets in s.
+func (s *rrSets) Len() int {
+	return len(s.offsets) - 1
+}
+
+// Bytes returns the memory used by s.
+func (s *rrSets) Bytes() int {
+	return len(s.data) + rr_set_overhead*len(s.offsets) + s.indexBytes + rr_node_overhead*len(s.index)
+}
+
+// perSet returns the average memory used per set of s, 0 when s is empty. Unless indexed is set the
+// node index is left out.
+func (s *rrSets) perSet(indexed bool) float64 {
+	if s.Len() == 0 {
+		return 0
+	}
+
+	b := len(s.data) + rr_set_overhead*s.Len()
+	if indexed {
+		b += s.indexBytes
+	}
+
+	return float64(b) / float64(s.Len())
+}
+
+// clear removes every set from an unindexed collection, keeping its memory.
+func (s *rrSets) clear() {
+	s.data = s.data[:0]
+	s.offsets = s.offsets[:1]
+}
+
+// add appends rr, which it sorts.
+func (s *rrSets) add(rr []util.Node) {
+	id := s.Len()
+	sort.Slice(rr, func(i, j int) bool { return rr[i] < rr[j] })
+	prev := util.Node(0)
+	for _, u := range rr {
+		s.data = binary.AppendUvarint(s.data, uint64(u-prev))
+		prev = u
+		if s.index != nil {
+			w := len(s.index[u])
+			s.index[u] = binary.AppendUvarint(s.index[u], uint64(id-s.last[u]))
+			s.indexBytes += len(s.index[u]) - w
+			s.last[u] = id
+			s.degree[u]++
+		}
+	}
+
+	s.offsets = append(s.offsets, len(s.data))
+}
+
+// set decodes the i-th RR set, the returned slice is only valid until the next call.
+func (s *rrSets) set(i int) []util.Node {
+	s.scratch = s.scratch[:0]
+	var u util.Node
+	for buf := s.data[s.offsets[i]:s.offsets[i+1]]; len(buf) > 0; {
+		gap, w := binary.Uvarint(buf)
+		buf = buf[w:]
+		u += util.Node(gap)
+		s.scratch = append(s.scratch, u)
+	}
+
+	return s.scratch
+}
+
+// each calls f with the ids of the sets containing u, in increasing order.
+func (s *rrSets) each(u util.Node, f func(id int)) {
+	var id int
+	for buf := s.index[u]; len(buf) > 0; {
+		gap, w := binary.Uvarint(buf)
+		buf = buf[w:]
+		id += int(gap)
+		f(id)
+	}
+}
+
+// covered returns the number of sets containing at least one node of seeds.
+func (s *rrSets) covered(seeds set.Set) int {
+	var cov int
+	for i := 0; i < s.Len(); i++ {
+		if coversAny(seeds, s.set(i)) {
+			cov++
+		}
+	}
+
+	return cov
+}
+
+// coversAny reports whether rr contains a node of seeds.
+func coversAny(seeds set.Set, rr []util.Node) bool {
+	for _, node := range rr {
+		if seeds.Contains(node) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rrSetsHeader identifies what a stored RR-set collection was sampled from and how it was sized.
+type rrSetsHeader struct {
+	Graph     uint64 // Graph.Checksum
+	Activated uint64 // checksum of the nodes excluded as roots
+	Seed      int64
+	Nodes     int64
+	// Lower bound on the optimal spread of K seeds the collection was sized with.
+	Opt   float64
+	K     int64
+	Count int64
+	Model string
+}
+
+// matches reports why a collection sampled as h cannot be used where want is needed, if it cannot.
+func (h *rrSetsHeader) matches(want *rrSetsHeader) error {
+	switch {
+	case h.Graph != want.Graph || h.Nodes != want.Nodes:
+		return fmt.Errorf("it was sampled on another graph")
+	case h.Model != want.Model:
+		return fmt.Errorf("it was sampled under model %s", h.Model)
+	case h.Seed != want.Seed:
+		return fmt.Errorf("it was sampled with seed %d", h.Seed)
+	case h.Activated != want.Activated:
+		return fmt.Errorf("it was sampled with other activated nodes")
+	}
+
+	return nil
+}
+
+// writeRRSets stores s with its header in path, replacing the file only once it is fully written.
+func writeRRSets(path string, h *rrSetsHeader, s *rrSets) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	h.Count = int64(s.Len())
+	bw := bufio.NewWriter(f)
+	if err := encodeRRSets(bw, h, s); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// encodeRRSets writes the header h and the sets of s to bw and flushes it.
+func encodeRRSets(bw *bufio.Writer, h *rrSetsHeader, s *rrSets) error {
+	if _, err := bw.WriteString(rr_file_magic); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint32(rr_file_version)); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, [7]uint64{h.Graph, h.Activated, uint64(h.Seed), uint64(h.Nodes), math.Float64bits(h.Opt), uint64(h.K), uint64(h.Count)}); err != nil {
+		return err
+	}
+	if err := writeChunk(bw, []byte(h.Model)); err != nil {
+		return err
+	}
+	for i := 0; i < s.Len(); i++ {
+		if err := writeChunk(bw, s.data[s.offsets[i]:s.offsets[i+1]]); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeChunk writes the uvarint length of b followed by b.
+func writeChunk(bw *bufio.Writer, b []byte) error {
+	if _, err := bw.Write(binary.AppendUvarint(nil, uint64(len(b)))); err != nil {
+		return err
+	}
+
+	_, err := bw.Write(b)
+	return err
+}
+
+// readRRSets loads the collection stored in path, indexed over n nodes. It returns a nil collection
+// if the file does not exist.
+func readRRSets(path string, n int) (*rrSetsHeader, *rrSets, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// No chunk is longer than the file, whatever length a corrupt file claims.
+	limit := uint64(fi.Size())
+	br := bufio.NewReader(f)
+	magic := make([]byte, len(rr_file_magic))
+	var version uint32
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != rr_file_magic {
+		return nil, nil, fmt.Errorf("%s: not an RR-set file", path)
+	}
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil || version != rr_file_version {
+		return nil, nil, fmt.Errorf("%s: unsupported RR-set file version %d", path, version)
+	}
+
+	var fields [7]uint64
+	if err := binary.Read(br, binary.LittleEndian, &fields); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	h := &rrSetsHeader{
+		Graph:     fields[0],
+		Activated: fields[1],
+		Seed:      int64(fields[2]),
+		Nodes:     int64(fields[3]),
+		Opt:       math.Float64frombits(fields[4]),
+		K:         int64(fields[5]),
+		Count:     int64(fields[6]),
+	}
+	model, err := readChunk(br, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	h.Model = string(model)
+	if h.Nodes != int64(n) {
+		return h, nil, nil
+	}
+
+	s := newRRSets(n, true)
+	for i := int64(0); i < h.Count; i++ {
+		b, err := readChunk(br, limit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: RR set %d: %v", path, i, err)
+		}
+
+		rr := make([]util.Node, 0)
+		var u util.Node
+		for len(b) > 0 {
+			gap, w := binary.Uvarint(b)
+			if w <= 0 {
+				return nil, nil, fmt.Errorf("%s: RR set %d: corrupt encoding", path, i)
+			}
+
+			// A gap of n or more would leave the nodes, or wrap them around to negative ids.
+			b = b[w:]
+			if gap >= uint64(n) || int(u)+int(gap) >= n {
+				return nil, nil, fmt.Errorf("%s: RR set %d: node %d + %d out of range", path, i, u, gap)
+			}
+			u += util.Node(gap)
+			rr = append(rr, u)
+		}
+
+		s.add(rr)
+	}
+
+	return h, s, nil
+}
+
+// readChunk reads a uvarint length, at most limit, followed by as many bytes.
+func readChunk(br *bufio.Reader, limit uint64) ([]byte, error) {
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	} else if l > limit {
+		return nil, fmt.Errorf("chunk of %d bytes in a file of %d", l, limit)
+	}
+
+	b := make([]byte, l)
+	_, err = io.ReadFull(br, b)
+	return b, err
+}
@@ -0,0 +1,252 @@
+package algorithm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// randomSets returns count RR sets of distinct nodes below n, some of them empty.
+func randomSets(count, n int, rnd *rand.Rand) [][]util.Node {
+	sets := make([][]util.Node, count)
+	for i := range sets {
+		size := rnd.Intn(20)
+		if i%7 == 0 {
+			size = 0
+		} else if size > n {
+			size = n
+		}
+
+		seen := make(map[util.Node]bool)
+		for len(sets[i]) < size {
+			u := util.Node(rnd.Int63n(int64(n)))
+			if !seen[u] {
+				seen[u] = true
+				sets[i] = append(sets[i], u)
+			}
+		}
+	}
+
+	return sets
+}
+
+func sorted(rr []util.Node) []util.Node {
+	s := append([]util.Node{}, rr...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s
+}
+
+func TestRRSetsRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 300, 1 << 40} {
+		indexed := n <= 300
+		sets := randomSets(500, n, rnd)
+		sets = append(sets, []util.Node{0, util.Node(n - 1)}) // the largest id with the widest gap
+		s := newRRSets(0, false)
+		if indexed {
+			s = newRRSets(n, true)
+		}
+		for _, rr := range sets {
+			s.add(append([]util.Node{}, rr...))
+		}
+
+		if s.Len() != len(sets) {
+			t.Fatalf("n=%d: %d sets, want %d", n, s.Len(), len(sets))
+		}
+		for i, rr := range sets {
+			if got := s.set(i); !reflect.DeepEqual(append([]util.Node{}, got...), sorted(rr)) && !(len(got) == 0 && len(rr) == 0) {
+				t.Errorf("n=%d: set %d is %v, want %v", n, i, got, sorted(rr))
+			}
+		}
+		if !indexed {
+			continue
+		}
+
+		// The index lists, for each node, the sets containing it in increasing order.
+		for u := 0; u < n; u++ {
+			var want, got []int
+			for i, rr := range sets {
+				for _, v := range rr {
+					if v == util.Node(u) {
+						want = append(want, i)
+					}
+				}
+			}
+			s.each(util.Node(u), func(id int) { got = append(got, id) })
+			if !reflect.DeepEqual(got, want) || s.degree[u] != len(want) {
+				t.Errorf("n=%d: node %d in sets %v (degree %d), want %v", n, u, got, s.degree[u], want)
+			}
+		}
+	}
+}
+
+func TestRRSetsBytes(t *testing.T) {
+	s := newRRSets(10, true)
+	empty := s.Bytes()
+	s.add([]util.Node{1, 2, 3})
+	if s.Bytes() <= empty || s.perSet(true) <= s.perSet(false) {
+		t.Errorf("Bytes() = %d after a set, %d empty; per set %v indexed, %v not", s.Bytes(), empty, s.perSet(true), s.perSet(false))
+	}
+
+	seeds := set.NewSet(util.Node(2))
+	s.add([]util.Node{4})
+	if c := s.covered(seeds); c != 1 {
+		t.Errorf("covered = %d, want 1", c)
+	}
+
+	s = newRRSets(0, false)
+	s.add([]util.Node{5})
+	s.clear()
+	if s.Len() != 0 || s.Bytes() != rr_set_overhead {
+		t.Errorf("cleared collection has %d sets and %d bytes", s.Len(), s.Bytes())
+	}
+}
+
+// wideSampler returns RR sets of the first size nodes.
+type wideSampler struct{ size int }
+
+func (w wideSampler) RRSet(root util.Node) []util.Node {
+	rr := make([]util.Node, w.size)
+	for i := range rr {
+		rr[i] = util.Node(i)
+	}
+	return rr
+}
+
+func TestMemoryBudget(t *testing.T) {
+	const n = 2000
+	sampler := wideSampler{n}
+	for _, policy := range []string{"", "error", "truncate"} {
+		config := &util.Config{MemoryBudget: 1, MemoryPolicy: policy}
+		c := &TIM{config: config, n: n, nodes: []util.Node{0}, rrSets: newRRSets(n, true)}
+		c.begin(context.Background(), config)
+		dst := grand.New(source64.NewXoShiRo256StarStar(1))
+
+		// The first sets fit, their size then tells that the rest would not.
+		c.extendSamples(10, sampler, dst)
+		if c.stopped() || c.rrSets.Len() != 10 {
+			t.Fatalf("policy %q: stopped with %d sets under the budget", policy, c.rrSets.Len())
+		}
+
+		c.extendSamples(10000, sampler, dst)
+		if !c.stopped() {
+			t.Fatalf("policy %q: not stopped over the budget", policy)
+		}
+		// Truncating keeps the set that reached the budget, and nothing more.
+		if over := float64(c.memory() - config.MemoryBudget<<20); over > c.rrSets.perSet(true) {
+			t.Errorf("policy %q: %.0f bytes over the budget", policy, over)
+		}
+
+		err := c.stopErr()
+		if policy == "truncate" {
+			if err != nil || !c.Truncated() || c.rrSets.Len() <= 10 {
+				t.Errorf("policy %q: err %v, truncated %t with %d sets, want the sets that fit", policy, err, c.Truncated(), c.rrSets.Len())
+			}
+		} else if !errors.Is(err, ErrMemoryBudget) || c.rrSets.Len() != 10 {
+			t.Errorf("policy %q: err %v with %d sets, want ErrMemoryBudget before sampling", policy, err, c.rrSets.Len())
+		}
+	}
+}
+
+func TestRRSetsFile(t *testing.T) {
+	const n = 300
+	path := filepath.Join(t.TempDir(), "rr.bin")
+	sets := randomSets(200, n, rand.New(rand.NewSource(2)))
+	s := newRRSets(n, true)
+	for _, rr := range sets {
+		s.add(append([]util.Node{}, rr...))
+	}
+
+	h := &rrSetsHeader{Graph: 11, Activated: 12, Seed: 13, Nodes: n, Opt: 4.5, K: 3, Model: "ic"}
+	if err := writeRRSets(path, h, s); err != nil {
+		t.Fatal(err)
+	}
+
+	got, stored, err := readRRSets(path, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, h) {
+		t.Errorf("header %+v, want %+v", got, h)
+	}
+	if stored.Len() != s.Len() || !reflect.DeepEqual(stored.data, s.data) || !reflect.DeepEqual(stored.degree, s.degree) {
+		t.Errorf("read %d sets, want the %d written", stored.Len(), s.Len())
+	}
+	if err := got.matches(h); err != nil {
+		t.Errorf("matches itself: %v", err)
+	}
+
+	for _, c := range []struct {
+		change func(h *rrSetsHeader)
+		err    string
+	}{
+		{func(h *rrSetsHeader) { h.Graph++ }, "another graph"},
+		{func(h *rrSetsHeader) { h.Nodes++ }, "another graph"},
+		{func(h *rrSetsHeader) { h.Model = "lt" }, "model ic"},
+		{func(h *rrSetsHeader) { h.Seed = 1 }, "seed 13"},
+		{func(h *rrSetsHeader) { h.Activated = 0 }, "activated nodes"},
+	} {
+		want := *h
+		c.change(&want)
+		if err := got.matches(&want); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("matches %+v = %v, want %q", want, err, c.err)
+		}
+	}
+
+	// The sets are only decoded for a graph of as many nodes.
+	if got, stored, err := readRRSets(path, n+1); err != nil || got == nil || stored != nil {
+		t.Errorf("read for %d nodes: %v, %v, %v", n+1, got, stored, err)
+	}
+	if got, stored, err := readRRSets(filepath.Join(t.TempDir(), "none"), n); got != nil || stored != nil || err != nil {
+		t.Errorf("read of a missing file: %v, %v, %v", got, stored, err)
+	}
+}
+
+func TestRRSetsFileCorrupt(t *testing.T) {
+	const n = 10
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rr.bin")
+	s := newRRSets(n, true)
+	s.add([]util.Node{1, 5, 9})
+	s.add([]util.Node{2})
+	if err := writeRRSets(path, &rrSetsHeader{Nodes: n, Model: "ic"}, s); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := len(rr_file_magic) + 4 + 7*8
+
+	huge := append([]byte{}, data[:header]...)
+	huge = binary.AppendUvarint(huge, 1<<50) // a model name longer than any file
+	for name, b := range map[string][]byte{
+		"magic":     append([]byte("GOIMXX"), data[len(rr_file_magic):]...),
+		"truncated": data[:len(data)-1],
+		"header":    data[:header-3],
+		"length":    huge,
+		"range":     append(append([]byte{}, data[:len(data)-1]...), 10), // node 2 + 10 is past n
+		// A last set of one gap of 2^63 + 1 wraps around to a negative id.
+		"wrap": append(append(append([]byte{}, data[:len(data)-2]...), 10), binary.AppendUvarint(nil, 1<<63+1)...),
+	} {
+		bad := filepath.Join(dir, name)
+		if err := os.WriteFile(bad, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readRRSets(bad, n); err == nil {
+			t.Errorf("%s: read without an error", name)
+		}
+	}
+}
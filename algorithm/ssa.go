@@ -0,0 +1,218 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"math"
+)
+
+// H. T. Nguyen, M. T. Thai, T. N. Dinh. Stop-and-Stare: Optimal Sampling Algorithms for Viral Marketing
+// in Billion-scale Networks, SIGMOD 2016.
+// SSA doubles the number of RR sets until the seed set found on them passes an independent
+// estimation of its influence. D-SSA derives the error split dynamically from the samples instead.
+type SSA struct {
+	TIM
+	delta float64
+}
+
+func init() {
+	Register(Registration{
+		Name:        "ssa",
+		Description: "Stop-and-Stare, doubling RR sets until an independent check confirms the influence (Nguyen et al. 2016)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewSSA(graph, config, t), nil
+		},
+		Options: stopAndStareOptions,
+	})
+}
+
+func NewSSA(graph *util.Graph, config *util.Config, t int) *SSA {
+	c := new(SSA)
+	c.TIM = *NewTIM(graph, config, t)
+	c.epsilon, c.delta = stopAndStareParams(config)
+	return c
+}
+
+func (c *SSA) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.reset(activated)
+	sampler, err := newRRSampler(c.graph, c.config, c.t)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := grand.New(c.src)
+
+	n := float64(len(c.nodes))
+	delta := failureProbability(c.delta, n)
+	// ε1, ε2 and ε3 are fixed so that (ε1 + ε2 + ε1ε2)(1-1/e-ε) + (1-1/e)ε3 <= ε.
+	e := 1 - 1/math.E
+	eps3 := c.epsilon / (2 * e)
+	eps1 := c.epsilon / (5 * (e - c.epsilon))
+	eps2 := eps1
+
+	nMax := maxSamples(n, c.k, c.epsilon, delta)
+	lambda1 := 1 + (1+eps1)*(1+eps2)*upsilon(eps3, delta/3)
+	lambda2 := 1 + (1+eps2)*upsilon(eps2, delta/3)
+	c.buildSamples(int(math.Ceil(lambda1)), sampler, dst)
+	for {
+		c.extendSamples(c.rrSets.Len(), sampler, dst)
+		if c.stopped() {
+			return c.stop()
+		}
+
+		c.buildSeedSet()
+		cov := c.coveredSets()
+		if float64(cov) >= lambda1 {
+			tMax := 2 * float64(c.rrSets.Len()) * (1 + eps2) / (1 - eps2) * (eps3 * eps3) / (eps2 * eps2)
+			influence := n * float64(cov) / float64(c.rrSets.Len())
+			if estimate := c.estimateInfluence(lambda2, tMax, n, sampler, dst); estimate > 0 && influence <= (1+eps1)*estimate {
+				break
+			}
+		}
+
+		if float64(c.rrSets.Len()) >= nMax {
+			break
+		}
+	}
+
+	return c.seeds, nil
+}
+
+// estimateInfluence estimates the influence of the current seed set with fresh RR sets, stopping
+// once lambda of them are covered. It returns -1 when more than tMax sets are needed or the selection
+// is stopped.
+func (c *SSA) estimateInfluence(lambda, tMax, n float64, sampler model.RRSampler, dst *grand.Rand) float64 {
+	var cov, T float64
+	for cov < lambda {
+		T++
+		if T > tMax || c.stopped() {
+			return -1
+		}
+
+		if coversAny(c.seeds, c.sampleRRSet(sampler, dst)) {
+			cov++
+		}
+	}
+
+	return n * lambda / T
+}
+
+// DSSA is the dynamic variant of SSA. Each round it draws as many check sets as selection sets, stops
+// once the error bounds computed from the coverage of both fall within epsilon, and otherwise merges
+// the check sets into the selection ones, doubling them.
+type DSSA struct {
+	TIM
+	delta float64
+	check *rrSets
+}
+
+func init() {
+	Register(Registration{
+		Name:        "dssa",
+		Description: "Dynamic Stop-and-Stare, SSA with error bounds derived from the samples (Nguyen et al. 2016)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewDSSA(graph, config, t), nil
+		},
+		Options: stopAndStareOptions,
+	})
+}
+
+func NewDSSA(graph *util.Graph, config *util.Config, t int) *DSSA {
+	c := new(DSSA)
+	c.TIM = *NewTIM(graph, config, t)
+	c.epsilon, c.delta = stopAndStareParams(config)
+	return c
+}
+
+func (c *DSSA) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.reset(activated)
+	sampler, err := newRRSampler(c.graph, c.config, c.t)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := grand.New(c.src)
+
+	n := float64(len(c.nodes))
+	delta := failureProbability(c.delta, n)
+	e := 1 - 1/math.E
+	nMax := maxSamples(n, c.k, c.epsilon, delta)
+	lambda1 := 1 + (1+c.epsilon)*upsilon(c.epsilon, delta/3)
+	c.buildSamples(int(math.Ceil(lambda1)), sampler, dst)
+	c.check = newRRSets(0, false)
+	c.aux = []*rrSets{c.check}
+	for {
+		if c.stopped() {
+			return c.stop()
+		}
+
+		c.buildSeedSet()
+		c.extend(c.check, c.rrSets.Len()-c.check.Len(), sampler, dst)
+		if c.stopped() {
+			return c.stop()
+		}
+
+		if cov := float64(c.coveredSets()); cov >= lambda1 {
+			influence := n * cov / float64(c.rrSets.Len())
+			checked := n * float64(c.check.covered(c.seeds)) / float64(c.check.Len())
+			if checked > 0 {
+				rounds := float64(c.rrSets.Len()) / lambda1 // 2^(t-1)
+				eps1 := influence/checked - 1
+				eps2 := c.epsilon * math.Sqrt(n*(1+c.epsilon)/(rounds*checked))
+				eps3 := c.epsilon * math.Sqrt(n*(1+c.epsilon)*(e-c.epsilon)/((1+c.epsilon/3)*rounds*checked))
+				if (eps1+eps2+eps1*eps2)*(e-c.epsilon)+e*eps3 <= c.epsilon {
+					break
+				}
+			}
+		}
+
+		if float64(c.rrSets.Len()) >= nMax {
+			break
+		}
+
+		// The check collection is merged into the selection one and a fresh one is drawn on the next round.
+		c.merge(c.check)
+	}
+
+	return c.seeds, nil
+}
+
+var stopAndStareOptions = append([]util.Option{
+	{Key: "epsilon", Description: "approximation error"},
+	{Key: "delta", Description: "failure probability, 1/n when unset"},
+}, rrSetOptions...)
+
+func stopAndStareParams(config *util.Config) (epsilon, delta float64) {
+	epsilon = config.Epsilon
+	if epsilon <= 0 {
+		epsilon = default_epsilon
+	}
+
+	return epsilon, config.Delta
+}
+
+// failureProbability returns delta, or 1/n when it is unset.
+func failureProbability(delta, n float64) float64 {
+	if delta <= 0 {
+		return 1 / n
+	}
+
+	return delta
+}
+
+// upsilon is the number of successes required by the stopping rule of Dagum et al. to estimate a mean
+// within relative error epsilon with probability at least 1-delta.
+func upsilon(epsilon, delta float64) float64 {
+	return 4 * (math.E - 2) * math.Log(2/delta) / (epsilon * epsilon)
+}
+
+// maxSamples is the number of RR sets after which the seed set is returned regardless of the checks.
+func maxSamples(n float64, k int, epsilon, delta float64) float64 {
+	e := 1 - 1/math.E
+	return 8 * e / (2 + 2*epsilon/3) * (4 * (math.E - 2) * (math.Log(12/delta) + logcnk(int(n), k)) / (epsilon * epsilon)) * n / float64(k)
+}
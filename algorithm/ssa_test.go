@@ -0,0 +1,113 @@
+package algorithm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
+	"strings"
+	"testing"
+)
+
+func TestStopAndStare(t *testing.T) {
+	g := testGraph(t)
+	const epsilon = 0.5
+	config := &util.Config{Seeds: 3, Model: "ic", Seed: 3, Epsilon: epsilon, Simulations: 20000}
+	ic, err := model.New("ic", g, config, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spread := make(map[string]float64)
+	for _, name := range []string{"tim", "ssa", "dssa"} {
+		a, err := New(name, g, config, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for trial := 0; trial < 2; trial++ {
+			seeds, err := a.Select(context.Background(), set.NewSet())
+			if err != nil || seeds.Len() != config.Seeds {
+				t.Fatalf("%s, trial %d: %v, %v", name, trial, seeds, err)
+			}
+			spread[name] = ic.Sample(set.NewSet(), seeds).Mean
+		}
+	}
+
+	for _, name := range []string{"ssa", "dssa"} {
+		if spread[name] < (1-epsilon)*spread["tim"] {
+			t.Errorf("%s spread %v, TIM %v", name, spread[name], spread["tim"])
+		}
+	}
+}
+
+// cycleGraph returns a cycle of n nodes with edges of probability 1, where every RR set holds every node.
+func cycleGraph(t *testing.T, n int) *util.Graph {
+	t.Helper()
+	var b strings.Builder
+	for u := 0; u < n; u++ {
+		fmt.Fprintf(&b, "%d %d 1\n", u, (u+1)%n)
+	}
+
+	g, err := util.ReadGraph(strings.NewReader(b.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestStopAndStareMemoryBudget(t *testing.T) {
+	g := cycleGraph(t, 5000)
+	for _, name := range []string{"ssa", "dssa"} {
+		config := &util.Config{Seeds: 1, Model: "ic", Seed: 3, Epsilon: 0.5, MemoryBudget: 1}
+		a, err := New(name, g, config, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Select(context.Background(), set.NewSet()); !errors.Is(err, ErrMemoryBudget) {
+			t.Errorf("%s: %v, want ErrMemoryBudget", name, err)
+		}
+
+		config.MemoryPolicy = "truncate"
+		if seeds, err := a.Select(context.Background(), set.NewSet()); err != nil || seeds.Len() != 1 {
+			t.Errorf("%s, truncated: %v, %v, want a seed", name, seeds, err)
+		}
+	}
+}
+
+func TestMergeMemoryBudget(t *testing.T) {
+	const n = 2000
+	sampler := wideSampler{n}
+	for _, policy := range []string{"", "truncate"} {
+		config := &util.Config{MemoryBudget: 1, MemoryPolicy: policy}
+		c := &TIM{config: config, n: n, nodes: []util.Node{0}, rrSets: newRRSets(n, true)}
+		c.begin(context.Background(), config)
+		dst := grand.New(source64.NewXoShiRo256StarStar(1))
+		c.extendSamples(10, sampler, dst)
+
+		// The check sets fit unindexed, not once indexed with the selection ones.
+		check := newRRSets(0, false)
+		c.aux = []*rrSets{check}
+		c.extend(check, 300, sampler, dst)
+		if c.stopped() || check.Len() != 300 {
+			t.Fatalf("policy %q: stopped with %d check sets", policy, check.Len())
+		}
+
+		c.merge(check)
+		if !c.stopped() {
+			t.Fatalf("policy %q: merged %d sets over the budget", policy, c.rrSets.Len())
+		}
+		if policy == "truncate" {
+			if err := c.stopErr(); err != nil || c.rrSets.Len() <= 10 || c.rrSets.Len() >= 310 {
+				t.Errorf("policy %q: err %v with %d sets merged, want the sets that fit", policy, err, c.rrSets.Len())
+			}
+		} else if err := c.stopErr(); !errors.Is(err, ErrMemoryBudget) || c.rrSets.Len() != 10 {
+			t.Errorf("policy %q: err %v with %d sets merged, want ErrMemoryBudget before merging", policy, err, c.rrSets.Len())
+		}
+	}
+}
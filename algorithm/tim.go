@@ -1,16 +1,18 @@
 package algorithm
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
 	"github.com/jtejido/goim/model"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 	"github.com/lucky-se7en/grand"
 	"github.com/lucky-se7en/grand/source64"
+	"hash/fnv"
 	"math"
-)
-
-const (
-	max_r = 10000000
+	"sort"
+	"strings"
 )
 
 type TIM struct {
@@ -18,11 +20,11 @@ type TIM struct {
 	covQueue   *util.PriorityQueue
 	graph      *util.Graph
 	config     *util.Config
-	hyperId    int
-	hyperGraph [][]util.Node
 	totalR     int
 	nodes      []util.Node
-	rrSets     [][]util.Node
+	rrSets     *rrSets
+	aux        []*rrSets // other collections counted against the memory budget
+	lastPerSet float64   // memory per set of the previous collection
 	seeds      set.Set
 	src        grand.Source64
 	activated  set.Set
@@ -34,6 +36,20 @@ type TIM struct {
 	t          int
 }
 
+func init() {
+	Register(Registration{
+		Name:        "tim",
+		Description: "Two-phase Influence Maximization on reverse-reachable sets (Tang et al. 2014)",
+		New: func(graph *util.Graph, config *util.Config, t int) (Algorithm, error) {
+			return NewTIM(graph, config, t), nil
+		},
+		Options: append(rrSetOptions[:len(rrSetOptions):len(rrSetOptions)],
+			util.Option{Key: "epsilon", Description: "approximation error"},
+			util.Option{Key: "rrSetsFile", Description: "file the RR sets are saved to and reused from"},
+		),
+	})
+}
+
 func NewTIM(graph *util.Graph, config *util.Config, t int) *TIM {
 	c := new(TIM)
 	c.graph = graph
@@ -41,63 +57,175 @@ func NewTIM(graph *util.Graph, config *util.Config, t int) *TIM {
 	c.src = source64.NewXoShiRo256StarStar(config.Seed)
 	c.seeds = set.NewUnsafeSet()
 	c.nodes = make([]util.Node, 0)
-	c.rrSets = make([][]util.Node, 0)
-	c.hyperGraph = make([][]util.Node, 0)
+	c.rrSets = newRRSets(0, true)
 	c.activated = set.NewUnsafeSet()
 	c.k = config.Seeds
 	c.t = t
+	c.epsilon = config.Epsilon
+	if c.epsilon <= 0 {
+		c.epsilon = default_epsilon
+	}
 	return c
 }
 
-func (c *TIM) Select(activated set.Set) set.Set {
+func (c *TIM) Select(ctx context.Context, activated set.Set) (set.Set, error) {
+	c.begin(ctx, c.config)
+	c.reset(activated)
+
+	sampler_s, err := newRRSampler(c.graph, c.config, c.t)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := grand.New(c.src)
+	if c.config.RRSetsFile != "" {
+		return c.selectStored(c.config.RRSetsFile, activated, sampler_s, dst)
+	}
+
+	opt := c.estimateOPT(sampler_s, dst)
+	if c.stopped() {
+		return c.stop()
+	}
+
+	c.buildHyperGraph3(c.epsilon, opt, sampler_s, dst)
+	return c.stop()
+}
+
+// estimateOPT runs the first two phases of TIM, returning a lower bound on the spread of the best k
+// seeds. It returns early once the selection is stopped.
+func (c *TIM) estimateOPT(sampler model.RRSampler, dst *grand.Rand) float64 {
+	ep_step2 := 5 * math.Pow(math.Sqrt(c.epsilon)/float64(c.k), 1./3)
+	ept := c.estimateEPT(sampler, dst)
+	if c.stopped() {
+		return ept
+	}
+
+	c.buildSeedSet()
+	c.buildHyperGraph2(ep_step2, ept, sampler, dst)
+	if c.stopped() {
+		return ept
+	}
+
+	return c.influenceHyperGraph() / (1 + ep_step2)
+}
+
+// selectStored picks the seeds on the RR sets saved in path by an earlier selection on the same
+// graph, model, seed and activated nodes, sampling and saving the collection first if path does not
+// exist. The stored lower bound on OPT is reused when it was estimated for at most k seeds, since OPT
+// grows with k, and the collection is topped up when it is smaller than the phase 3 size for
+// Config.Epsilon. Sets added to a collection of n sets are drawn from a generator seeded by
+// Config.Seed and n, so that a collection grows the same way whichever run tops it up.
+func (c *TIM) selectStored(path string, activated set.Set, sampler model.RRSampler, dst *grand.Rand) (set.Set, error) {
+	want := &rrSetsHeader{
+		Graph:     c.graph.Checksum(),
+		Activated: nodesChecksum(activated),
+		Seed:      c.config.Seed,
+		Nodes:     int64(c.n),
+		Model:     c.config.SelectionModelName(),
+	}
+	h, stored, err := readRRSets(path, c.n)
+	if err != nil {
+		return nil, err
+	}
+	if h != nil {
+		if err := h.matches(want); err != nil {
+			return nil, fmt.Errorf("%s: cannot reuse the RR sets, %v", path, err)
+		}
+
+		want.Opt, want.K = h.Opt, h.K
+	} else {
+		stored = newRRSets(c.n, true)
+	}
+
+	dirty := h == nil
+	if h == nil || h.K > int64(c.k) {
+		c.aux = []*rrSets{stored}
+		opt := c.estimateOPT(sampler, dst)
+		c.aux = nil
+		if c.stopped() {
+			return c.stop()
+		}
+
+		want.Opt, want.K, dirty = opt, int64(c.k), true
+		c.lastPerSet = c.rrSets.perSet(true)
+	}
+
+	c.rrSets = stored
+	if R := c.theta(c.epsilon, want.Opt); stored.Len() < R {
+		src := source64.NewXoShiRo256StarStar(util.DeriveSeed(c.config.Seed, int64(stored.Len())))
+		c.totalR += R - stored.Len()
+		c.extendSamples(R-stored.Len(), sampler, grand.New(src))
+		dirty = true
+	}
+
+	// A collection cut short by the time or memory budget is still a valid sample to top up later.
+	if dirty && c.stopErr() == nil {
+		if err := writeRRSets(path, want, c.rrSets); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.stop()
+}
+
+// stop greedily picks the seeds on the RR sets sampled so far and returns them, with the error of
+// the selection if it was stopped.
+func (c *TIM) stop() (set.Set, error) {
+	if c.rrSets.Len() == 0 {
+		c.seeds.Clear()
+	} else {
+		c.buildSeedSet()
+	}
+
+	return c.seeds, c.stopErr()
+}
+
+// newRRSampler returns the RR-set sampler of the selection model.
+func newRRSampler(graph *util.Graph, config *util.Config, t int) (model.RRSampler, error) {
+	m, err := model.New(config.SelectionModelName(), graph, config, t)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, ok := m.(model.RRSampler)
+	if !ok {
+		return nil, fmt.Errorf("diffusion model %s does not generate RR sets", config.SelectionModelName())
+	}
+
+	return sampler, nil
+}
+
+// reset prepares the sampling state for a new round, excluding already activated nodes
+// from the candidate sources of RR sets.
+func (c *TIM) reset(activated set.Set) {
 	c.seeds.Clear()
 	for node := range activated.Iter() {
 		c.activated.Add(node.(util.Node))
 	}
 
-	c.n = c.graph.Nodes().Len()
+	c.n = c.graph.NumNodes()
 	c.m = c.graph.NumEdges()
-	c.hyperId = 0
 	c.totalR = 0
-	c.epsilon = 0.1
+	c.aux = nil
 
 	c.nodes = make([]util.Node, 0)
 	c.nMax = 0
-	for src := range c.graph.Nodes().Iter() {
-		source := src.(util.Node)
+	for u := 0; u < c.n; u++ {
+		source := util.Node(u)
 		if !(activated.Contains(source)) {
-			if int(source) > c.nMax {
-				c.nMax = int(source)
-			}
+			c.nMax = u
 			c.nodes = append(c.nodes, source)
 		}
 	}
-
-	sampler_s := model.NewIndependentCascade(c.graph, c.config, c.t)
-	dst := grand.New(c.src)
-	var ep_step2, ep_step3 float64
-	ep_step3 = c.epsilon
-	ep_step2 = 5 * math.Pow(math.Sqrt(ep_step3)/float64(c.config.Seeds), 1./3)
-	var ept float64
-
-	ept = c.estimateEPT(sampler_s, dst)
-
-	c.buildSeedSet()
-	c.buildHyperGraph2(ep_step2, ept, sampler_s, dst)
-	ept = c.influenceHyperGraph()
-	ept /= 1 + ep_step2
-	c.buildHyperGraph3(ep_step3, ept, sampler_s, dst)
-	c.buildSeedSet()
-	return c.seeds
 }
 
-func (c *TIM) estimateEPT(sampler *model.IndependentCascade, dst *grand.Rand) float64 {
+func (c *TIM) estimateEPT(sampler model.RRSampler, dst *grand.Rand) float64 {
 	ept := c.estimateKPT(sampler, dst)
 	ept /= 2
 	return ept
 }
 
-func (c *TIM) estimateKPT(sampler *model.IndependentCascade, dst *grand.Rand) float64 {
+func (c *TIM) estimateKPT(sampler model.RRSampler, dst *grand.Rand) float64 {
 	lb := 1. / 2
 	var cc float64
 	var lastR int
@@ -108,20 +236,20 @@ func (c *TIM) estimateKPT(sampler *model.IndependentCascade, dst *grand.Rand) fl
 		cc = 0
 		lastR = loop
 
+		sampled := make([][]util.Node, 0, loop)
 		for i := 0; i < loop; i++ {
-			rr := make([]util.Node, 0)
-
-			seeds := set.NewSet()
-			u := c.nodes[dst.Intn(len(c.nodes)-1)]
-			seeds.Add(u)
-			rr = append(rr, u)
-			sampler.Trial(c.activated, seeds, true)
-			for _, tt := range sampler.Trials() {
-				if tt.Trial == 1 {
-					rr = append(rr, tt.Target)
+			if c.stopped() {
+				// The RR sets of this round are kept to pick the seeds on.
+				c.buildSamples(0, sampler, dst)
+				for _, rr := range sampled {
+					c.rrSets.add(rr)
 				}
+				return ret
 			}
 
+			rr := c.sampleRRSet(sampler, dst)
+			sampled = append(sampled, rr)
+
 			var mg_tu float64
 			for _, node := range rr {
 				mg_tu += float64(len(c.graph.Neighbors(node, true)))
@@ -142,57 +270,135 @@ func (c *TIM) estimateKPT(sampler *model.IndependentCascade, dst *grand.Rand) fl
 	return ret
 }
 
-func (c *TIM) buildSamples(R int, sampler *model.IndependentCascade, dst *grand.Rand) {
+// buildSamples replaces the collection with R new RR sets.
+func (c *TIM) buildSamples(R int, sampler model.RRSampler, dst *grand.Rand) {
 	c.totalR += R
-
-	if R > max_r {
-		R = max_r
+	if per := c.rrSets.perSet(true); per > 0 {
+		c.lastPerSet = per
 	}
 
-	c.hyperId = R
-	c.hyperGraph = make([][]util.Node, c.n)
-	c.rrSets = make([][]util.Node, 0)
+	c.rrSets = newRRSets(c.n, true)
+	c.extendSamples(R, sampler, dst)
+}
 
-	var totInDegree float64
+// growSamples extends the collection to R RR sets, keeping the ones sampled so far.
+func (c *TIM) growSamples(R int, sampler model.RRSampler, dst *grand.Rand) {
+	if R > c.rrSets.Len() {
+		c.totalR += R - c.rrSets.Len()
+		c.extendSamples(R-c.rrSets.Len(), sampler, dst)
+	}
+}
 
-	for i := 0; i < R; i++ {
+// extendSamples adds R new RR sets to the current collection, keeping the existing ones. It adds
+// fewer once the selection is stopped.
+func (c *TIM) extendSamples(R int, sampler model.RRSampler, dst *grand.Rand) {
+	c.extend(c.rrSets, R, sampler, dst)
+}
 
-		rr := make([]util.Node, 0)
-		seeds := set.NewSet()
-		nd := c.nodes[dst.Intn(len(c.nodes)-1)]
-		seeds.Add(nd)
-		rr = append(rr, nd)
-		sampler.Trial(c.activated, seeds, true)
+// extend adds R new RR sets to rr. When they do not fit in Config.MemoryBudget, it stops the
+// selection once the budget is reached or, unless the memory policy truncates, before sampling
+// if that can be foreseen from the size of the sets sampled so far.
+func (c *TIM) extend(rr *rrSets, R int, sampler model.RRSampler, dst *grand.Rand) {
+	budget := c.config.MemoryBudget << 20
+	if budget <= 0 {
+		for i := 0; i < R && !c.stopped(); i++ {
+			rr.add(c.sampleRRSet(sampler, dst))
+		}
+		return
+	}
 
-		totInDegree += float64(len(sampler.Trials()))
-		for _, tt := range sampler.Trials() {
-			if tt.Trial == 1 {
-				rr = append(rr, tt.Target)
-			}
+	per := rr.perSet(rr.index != nil)
+	if per == 0 && rr.index != nil {
+		per = c.lastPerSet
+	} else if per == 0 {
+		per = c.rrSets.perSet(false)
+	}
+	if need := float64(c.memory()) + float64(R)*per; need > float64(budget) && !c.truncates() {
+		c.overBudget(rr.Len()+R, need)
+		return
+	}
+
+	for i := 0; i < R && !c.stopped(); i++ {
+		rr.add(c.sampleRRSet(sampler, dst))
+		if m := c.memory(); m > budget {
+			c.overBudget(rr.Len()+R-i-1, float64(m)+float64(R-i-1)*rr.perSet(rr.index != nil))
+			return
 		}
-		c.rrSets = append(c.rrSets, rr)
+	}
+}
 
+// merge moves the sets of src into the current collection, which src must not be. Like extend, it
+// stops the selection when they do not fit in Config.MemoryBudget.
+func (c *TIM) merge(src *rrSets) {
+	budget := c.config.MemoryBudget << 20
+	per := c.rrSets.perSet(true)
+	if per == 0 {
+		per = src.perSet(false)
+	}
+	if need := float64(c.memory()) + float64(src.Len())*per; budget > 0 && need > float64(budget) && !c.truncates() {
+		c.overBudget(c.rrSets.Len()+src.Len(), need)
+		return
 	}
 
-	for i := 0; i < R; i++ {
-		for _, t := range c.rrSets[i] {
-			if c.hyperGraph[int(t)] == nil {
-				c.hyperGraph[int(t)] = make([]util.Node, 0)
-			}
-			c.hyperGraph[int(t)] = append(c.hyperGraph[int(t)], util.Node(i))
+	for i := 0; i < src.Len() && !c.stopped(); i++ {
+		c.rrSets.add(src.set(i))
+		if m := c.memory(); budget > 0 && m > budget {
+			c.overBudget(c.rrSets.Len()+src.Len()-i-1, float64(m)+float64(src.Len()-i-1)*c.rrSets.perSet(true))
+			return
 		}
 	}
+	src.clear()
 }
 
-func (c *TIM) buildSeedSet() {
+// memory returns the memory used by the RR-set collections.
+func (c *TIM) memory() int {
+	m := c.rrSets.Bytes()
+	for _, rr := range c.aux {
+		m += rr.Bytes()
+	}
+
+	return m
+}
+
+// truncates reports whether the memory policy keeps the RR sets that fit in the budget.
+func (c *TIM) truncates() bool {
+	return strings.ToLower(c.config.MemoryPolicy) == "truncate"
+}
+
+// overBudget stops the selection because a collection of count RR sets would bring the memory used
+// to need bytes, over Config.MemoryBudget. Unless Config.MemoryPolicy is "truncate", the selection
+// fails with ErrMemoryBudget.
+func (c *TIM) overBudget(count int, need float64) {
+	if c.truncates() {
+		c.abort(nil)
+		return
+	}
+
+	c.abort(fmt.Errorf("%w: %d RR sets need about %.0f MiB, the budget is %d MiB", ErrMemoryBudget, count, need/(1<<20), c.config.MemoryBudget))
+}
+
+// sampleRRSet generates a single reverse-reachable set rooted at a random non-activated node.
+func (c *TIM) sampleRRSet(sampler model.RRSampler, dst *grand.Rand) []util.Node {
+	return sampler.RRSet(c.nodes[dst.Intn(len(c.nodes))])
+}
+
+// buildSeedSet greedily picks k nodes covering the most RR sets. It returns an upper bound on the
+// number of RR sets any k nodes can cover, taken as the tightest of the bounds found at each greedy step.
+func (c *TIM) buildSeedSet() int {
 	c.seeds.Clear()
 	deg := make([]int, c.n)
-	visit_local := make(map[util.Node]struct{}, len(c.rrSets))
+	visit_local := make([]bool, c.rrSets.Len())
+	var cov int
 	for i := 0; i < len(c.nodes); i++ {
-		deg[int(c.nodes[i])] = len(c.hyperGraph[c.nodes[i]])
+		deg[int(c.nodes[i])] = c.rrSets.degree[c.nodes[i]]
 	}
 
+	upper := math.MaxInt
 	for i := 0; i < c.k; i++ {
+		if bound := cov + topKSum(deg, c.k); bound < upper {
+			upper = bound
+		}
+
 		t := -1
 		id := -1
 		for j := 0; j < len(deg); j++ {
@@ -204,35 +410,90 @@ func (c *TIM) buildSeedSet() {
 
 		c.seeds.Add(util.Node(id))
 		deg[id] = 0
-		for _, t := range c.hyperGraph[id] {
-			if _, ok := visit_local[t]; !ok {
-				visit_local[t] = struct{}{}
-				for _, item := range c.rrSets[t] {
+		c.rrSets.each(util.Node(id), func(t int) {
+			if !visit_local[t] {
+				visit_local[t] = true
+				cov++
+				for _, item := range c.rrSets.set(t) {
 					deg[int(item)]--
 				}
 			}
+		})
+	}
+
+	return upper
+}
+
+// topKSum returns the sum of the k largest values in deg.
+func topKSum(deg []int, k int) int {
+	if k <= 0 {
+		return 0
+	}
+
+	top := make([]int, 0, k) // kept in ascending order
+	for _, d := range deg {
+		if len(top) < k {
+			i := sort.SearchInts(top, d)
+			top = append(top, 0)
+			copy(top[i+1:], top[i:])
+			top[i] = d
+		} else if d > top[0] {
+			i := sort.SearchInts(top, d)
+			copy(top[:i-1], top[1:i])
+			top[i-1] = d
 		}
 	}
+
+	var sum int
+	for _, d := range top {
+		sum += d
+	}
+
+	return sum
 }
 
 func (c *TIM) influenceHyperGraph() float64 {
-	s := make(map[util.Node]struct{})
+	inf := float64(c.n * c.coveredSets() / c.rrSets.Len())
+	return inf
+}
+
+// coverage returns the fraction of RR sets covered by the current seed set.
+func (c *TIM) coverage() float64 {
+	if c.rrSets.Len() == 0 {
+		return 0
+	}
+
+	return float64(c.coveredSets()) / float64(c.rrSets.Len())
+}
+
+// coveredSets returns the number of RR sets of the collection covered by the current seed set.
+func (c *TIM) coveredSets() int {
+	s := make([]bool, c.rrSets.Len())
+	var cov int
 	for t := range c.seeds.Iter() {
-		for _, tt := range c.hyperGraph[int(t.(util.Node))] {
-			s[tt] = struct{}{}
-		}
+		c.rrSets.each(t.(util.Node), func(id int) {
+			if !s[id] {
+				s[id] = true
+				cov++
+			}
+		})
 	}
 
-	inf := float64(c.n * len(s) / c.hyperId)
-	return inf
+	return cov
 }
 
-func (c *TIM) buildHyperGraph2(epsilon_, ept float64, sampler *model.IndependentCascade, dst *grand.Rand) {
+func (c *TIM) buildHyperGraph2(epsilon_, ept float64, sampler model.RRSampler, dst *grand.Rand) {
 	R := (8 + 2*epsilon_) * (float64(c.n)*math.Log(float64(c.n)) + float64(c.n)*math.Log(2)) / (epsilon_ * epsilon_ * ept) / 4
 	c.buildSamples(int(R), sampler, dst)
 }
 
-func (c *TIM) buildHyperGraph3(epsilon_, opt float64, sampler *model.IndependentCascade, dst *grand.Rand) {
+func (c *TIM) buildHyperGraph3(epsilon_, opt float64, sampler model.RRSampler, dst *grand.Rand) {
+	c.buildSamples(c.theta(epsilon_, opt), sampler, dst)
+}
+
+// theta returns the number of RR sets phase 3 samples for approximation error epsilon_ given a lower
+// bound opt on the spread of the best k seeds.
+func (c *TIM) theta(epsilon_, opt float64) int {
 	logCnk := 0.0
 	j := 1
 	for i := c.n; j <= c.k; i-- {
@@ -240,5 +501,23 @@ func (c *TIM) buildHyperGraph3(epsilon_, opt float64, sampler *model.Independent
 		j++
 	}
 	R := (8 + 2*epsilon_) * (float64(c.n)*math.Log(float64(c.n)) + float64(c.n)*math.Log(2) + float64(c.n)*logCnk) / (epsilon_ * epsilon_ * opt)
-	c.buildSamples(int(R), sampler, dst)
+	return int(R)
+}
+
+// nodesChecksum returns a 64-bit FNV-1a hash of the nodes of s in increasing order.
+func nodesChecksum(s set.Set) uint64 {
+	nodes := make([]util.Node, 0, s.Len())
+	for node := range s.Iter() {
+		nodes = append(nodes, node.(util.Node))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, u := range nodes {
+		binary.LittleEndian.PutUint64(buf[:], uint64(u))
+		h.Write(buf[:])
+	}
+
+	return h.Sum64()
 }
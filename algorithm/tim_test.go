@@ -0,0 +1,85 @@
+package algorithm
+
+import (
+	"context"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rootSampler returns RR sets made of their root only.
+type rootSampler struct{}
+
+func (rootSampler) RRSet(root util.Node) []util.Node {
+	return []util.Node{root}
+}
+
+func TestSampleRRSetRoots(t *testing.T) {
+	dst := grand.New(source64.NewXoShiRo256StarStar(1))
+	for _, nodes := range [][]util.Node{{4}, {0, 1}, {2, 5, 9}} {
+		c := &TIM{nodes: nodes}
+		seen := make(map[util.Node]bool)
+		for i := 0; i < 100; i++ {
+			seen[c.sampleRRSet(rootSampler{}, dst)[0]] = true
+		}
+		for _, u := range nodes {
+			if !seen[u] {
+				t.Errorf("candidates %v: node %d never drawn as a root", nodes, u)
+			}
+		}
+		if len(seen) != len(nodes) {
+			t.Errorf("candidates %v: drew roots %v", nodes, seen)
+		}
+	}
+}
+
+func TestSelectStored(t *testing.T) {
+	g := testGraph(t)
+	config := &util.Config{Model: "ic", Seed: 7, Epsilon: 0.5}
+	run := func(path string, k int) (*rrSetsHeader, *rrSets) {
+		t.Helper()
+		config.Seeds, config.RRSetsFile = k, path
+		if _, err := NewTIM(g, config, 0).Select(context.Background(), set.NewSet()); err != nil {
+			t.Fatalf("k=%d: %v", k, err)
+		}
+
+		h, s, err := readRRSets(path, g.NumNodes())
+		if err != nil || s == nil {
+			t.Fatalf("k=%d: reading the stored sets: %v", k, err)
+		}
+		return h, s
+	}
+
+	path := filepath.Join(t.TempDir(), "rr.bin")
+	h1, s1 := run(path, 1)
+	if h1.K != 1 || h1.Count == 0 || int(h1.Count) != s1.Len() {
+		t.Fatalf("stored %+v with %d sets", h1, s1.Len())
+	}
+
+	// More seeds reuse the bound on OPT and only append the sets phase 3 now needs.
+	h3, s3 := run(path, 3)
+	if h3.K != 1 || h3.Opt != h1.Opt || s3.Len() <= s1.Len() {
+		t.Errorf("k=3: stored %+v with %d sets, want the k=1 bound and more than %d sets", h3, s3.Len(), s1.Len())
+	}
+	if !reflect.DeepEqual(s3.data[:len(s1.data)], s1.data) {
+		t.Error("k=3: the sets stored for k=1 changed")
+	}
+
+	// Fewer seeds than the bound was estimated for estimate it again.
+	other := filepath.Join(t.TempDir(), "rr.bin")
+	run(other, 3)
+	if h, _ := run(other, 1); h.K != 1 {
+		t.Errorf("k=1 after k=3: stored %+v, want a bound for 1 seed", h)
+	}
+
+	// Another selection seed cannot reuse the file.
+	config.Seed = 8
+	if _, err := NewTIM(g, config, 0).Select(context.Background(), set.NewSet()); err == nil || !strings.Contains(err.Error(), "cannot reuse") {
+		t.Errorf("Select with another seed = %v, want a reuse error", err)
+	}
+}
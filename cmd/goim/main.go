@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/jtejido/goim/algorithm"
+	"github.com/jtejido/goim/evaluator"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	conf       *util.Config
+	cpuprofile string
+	logFile    string
+	confFile   string
+	seedFile   string
+	evalModels string
+)
+
+func init() {
+	flag.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to location")
+	flag.StringVar(&logFile, "log", "", "write log to location")
+	flag.StringVar(&confFile, "conf", "config.toml", "config file location")
+
+	conf, _ = util.LoadConfig(confFile)
+	flag.StringVar(&conf.OutputDir, "output", conf.OutputDir, "Path for output files.")
+	flag.StringVar(&conf.GraphPath, "graph", conf.GraphPath, "Path of graph file, possibly .gz, .bz2 or .zst compressed, or - for standard input.")
+	flag.StringVar(&conf.Weighting, "weighting", conf.Weighting, "Edge weighting replacing the graph file's probabilities (const:p, wc, tv, uniform or random).")
+	flag.Int64Var(&conf.Seed, "seed", conf.Seed, "Seed of rng.")
+	flag.IntVar(&conf.Trials, "trials", conf.Trials, "Number of trials.")
+	flag.StringVar(&conf.Algorithm, "algorithm", conf.Algorithm, "Seed-selection algorithm, \"list\" prints the registered ones.")
+	flag.IntVar(&conf.Seeds, "seeds", conf.Seeds, "Number of seeds in each trial.")
+	flag.StringVar(&conf.Model, "model", conf.Model, "Diffusion model to use, \"list\" prints the registered ones.")
+	flag.StringVar(&conf.SelectionModel, "selectionModel", conf.SelectionModel, "Diffusion model assumed by seed selection (defaults to -model).")
+	flag.StringVar(&evalModels, "evaluationModel", "", "Comma-separated models (model or model:graphPath) to score seeds under (defaults to -model).")
+	flag.StringVar(&conf.BenchFormat, "benchFormat", conf.BenchFormat, "Output format of the bench command (csv or json).")
+	flag.StringVar(&seedFile, "seedFile", "", "File of seed nodes to score with the evaluate command.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [evaluate|bench|weights|clean|convert]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if evalModels != "" {
+		conf.EvaluationModel = strings.Split(evalModels, ",")
+	}
+
+	if strings.EqualFold(conf.Algorithm, "list") || strings.EqualFold(conf.Model, "list") {
+		list()
+		return
+	}
+
+	if logFile != "" {
+		lf, err := os.Create(logFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.SetOutput(lf)
+	}
+
+	if cpuprofile != "" {
+		f, err := os.Create(cpuprofile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pprof.StartCPUProfile(f)
+		defer pprof.StopCPUProfile()
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			log.Fatalf("fatal: %v\n", err)
+		}
+	}()
+
+	// An interrupt stops the running selection.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch flag.Arg(0) {
+	case "":
+		run(ctx)
+	case "evaluate":
+		evaluate()
+	case "bench":
+		bench(ctx)
+	case "weights":
+		weights()
+	case "clean":
+		clean()
+	case "convert":
+		convert()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func run(ctx context.Context) {
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	logFileName := conf.LogFileName()
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(logFileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	bw := bufio.NewWriter(f)
+	eval, err := evaluator.NewEvaluator(conf, graph, logFileName, bw)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Println("Running Evaluator")
+	if err := eval.Run(ctx); err != nil {
+		log.Fatal(err.Error())
+	}
+	f.Close()
+}
+
+// evaluate scores the seeds listed in seedFile under the configured model.
+func evaluate() {
+	if seedFile == "" {
+		log.Fatal("evaluate requires -seedFile")
+	}
+
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	seeds, err := graph.LoadSeeds(seedFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	logFileName := conf.EvaluationFileName()
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(logFileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	log.Printf("Output: %s", logFileName)
+	if err := evaluator.EvaluateSeeds(conf, graph, seeds, bufio.NewWriter(f)); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// bench compares the spread-vs-k curves of the seed-selection algorithms.
+func bench(ctx context.Context) {
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fileName := conf.BenchFileName()
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	log.Printf("Output: %s", fileName)
+	bw := bufio.NewWriter(f)
+	if err := evaluator.Bench(ctx, conf, graph, bw); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := bw.Flush(); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// weights writes the graph weighted by conf.Weighting to a new graph file.
+func weights() {
+	if conf.Weighting == "" {
+		log.Fatal("weights requires -weighting")
+	}
+
+	w, err := util.ParseWeighting(conf.Weighting)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fileName := conf.WeightsFileName(w)
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	log.Printf("Output: %s", fileName)
+	if err := graph.Write(f); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// clean writes the graph without self-loops and duplicate edges, restricted to its largest component,
+// to a new graph file.
+func clean() {
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	cleaned, stats, err := util.Clean(graph, conf.CleanOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	log.Printf("Removed %d self-loops, %d duplicate edges, %d nodes and %d edges outside the largest component \n", stats.SelfLoops, stats.Duplicates, stats.Nodes, stats.Edges)
+	log.Printf("Cleaned graph: %d nodes, %d edges \n", cleaned.NumNodes(), cleaned.NumEdges())
+	fileName := conf.CleanFileName()
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	log.Printf("Output: %s", fileName)
+	if err := cleaned.Write(f); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// convert writes the graph, weighted by conf.Weighting if set, to a binary graph file that later runs
+// load without parsing.
+func convert() {
+	graph, err := util.NewGraph(conf.GraphPath, conf.ReadOptions())
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fileName := conf.BinaryFileName()
+	os.Mkdir(conf.OutputDir, os.ModeDir)
+	f, err := os.Create(fileName)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	log.Printf("Output: %s", fileName)
+	if err := graph.WriteBinary(f); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// list prints the registered algorithms or diffusion models with the config keys they read.
+func list() {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if strings.EqualFold(conf.Algorithm, "list") {
+		fmt.Fprintln(tw, "Algorithms:")
+		for _, r := range algorithm.Registered() {
+			printRegistration(tw, r.Name, r.Description, r.Options)
+		}
+	} else {
+		fmt.Fprintln(tw, "Diffusion models:")
+		for _, r := range model.Registered() {
+			printRegistration(tw, r.Name, r.Description, r.Options)
+		}
+	}
+	tw.Flush()
+}
+
+func printRegistration(w io.Writer, name, description string, options []util.Option) {
+	fmt.Fprintf(w, "  %s\t%s\n", name, description)
+	for _, o := range options {
+		fmt.Fprintf(w, "  \t  %s: %s\n", o.Key, o.Description)
+	}
+}
@@ -0,0 +1,214 @@
+package evaluator
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jtejido/goim/algorithm"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"io"
+	"log"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BenchResult is the outcome of one algorithm selecting k seeds.
+type BenchResult struct {
+	Algorithm string  `json:"algorithm"`
+	K         int     `json:"k"`
+	Mean      float64 `json:"mean"`
+	StdErr    float64 `json:"stdErr"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+	Seconds   float64 `json:"seconds"`
+	// Heap bytes allocated during the selection, freed or not. It bounds the peak growth of the heap
+	// from above, and is reached when nothing is collected during the selection.
+	AllocBytes uint64 `json:"allocBytes"`
+	Truncated  bool   `json:"truncated"`
+	// Labels of the seeds in the graph file.
+	Seeds []string `json:"seeds"`
+}
+
+// Bench runs every benchmarked algorithm for k = 1..Config.Seeds and scores each seed set with the
+// first evaluation model using Config.BenchSimulations simulations. Greedy algorithms run once for
+// Config.Seeds seeds, their first k seeds being their selection of k seeds, and the rows of each k
+// report the time and allocations of that single run. The table is written to w as CSV or JSON
+// depending on Config.BenchFormat. Config.TimeLimit bounds each selection.
+func Bench(ctx context.Context, config *util.Config, graph *util.Graph, w io.Writer) error {
+	evalConfig := *config
+	evalConfig.Simulations = config.BenchSimulations
+	if evalConfig.Simulations <= 0 {
+		evalConfig.Simulations = 10 * config.Simulations
+	}
+
+	evaluations, err := newEvaluations(&evalConfig, graph)
+	if err != nil {
+		return err
+	}
+	ev := evaluations[0]
+
+	names := config.BenchAlgorithms
+	if len(names) == 0 {
+		for _, r := range algorithm.Registered() {
+			names = append(names, r.Name)
+		}
+	}
+
+	results := make([]BenchResult, 0)
+	for _, a := range names {
+		cfg := *config
+		cfg.Algorithm = a
+		algo, err := algorithm.New(a, graph, &cfg, INFLUENCE_MED)
+		if err != nil {
+			return err
+		}
+
+		if g, ok := algo.(algorithm.Greedy); ok {
+			r, err := benchSelect(ctx, strings.ToUpper(a), algo)
+			if err != nil {
+				return err
+			}
+
+			prefix := set.NewSet()
+			for _, u := range g.Order() {
+				prefix.Add(u)
+				result, err := r.score(ctx, graph, ev.model, prefix)
+				if err != nil {
+					return err
+				}
+				results = append(results, result)
+			}
+			continue
+		}
+
+		for k := 1; k <= config.Seeds; k++ {
+			cfg.Seeds = k
+			if algo, err = algorithm.New(a, graph, &cfg, INFLUENCE_MED); err != nil {
+				return err
+			}
+
+			r, err := benchSelect(ctx, strings.ToUpper(a), algo)
+			if err != nil {
+				return err
+			}
+
+			result, err := r.score(ctx, graph, ev.model, r.seeds)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+	}
+
+	if strings.ToLower(config.BenchFormat) == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"algorithm", "k", "mean", "stdErr", "lower", "upper", "seconds", "allocBytes", "truncated", "seeds"})
+	for _, r := range results {
+		cw.Write([]string{
+			r.Algorithm,
+			fmt.Sprintf("%d", r.K),
+			fmt.Sprintf("%.5f", r.Mean),
+			fmt.Sprintf("%.5f", r.StdErr),
+			fmt.Sprintf("%.5f", r.Lower),
+			fmt.Sprintf("%.5f", r.Upper),
+			fmt.Sprintf("%.5f", r.Seconds),
+			fmt.Sprintf("%d", r.AllocBytes),
+			fmt.Sprintf("%t", r.Truncated),
+			strings.Join(r.Seeds, " "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// benchRun is a timed selection of seeds.
+type benchRun struct {
+	algorithm  string
+	seeds      set.Set
+	seconds    float64
+	allocBytes uint64
+	truncated  bool
+}
+
+// benchSelect selects seeds with algo, named name, measuring its time and allocations.
+func benchSelect(ctx context.Context, name string, algo algorithm.Algorithm) (*benchRun, error) {
+	runtime.GC()
+	allocs := heapAllocs()
+	t0 := time.Now()
+	seeds, err := algo.Select(ctx, set.NewSet())
+	elapsed := time.Since(t0)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &benchRun{algorithm: name, seeds: seeds, seconds: elapsed.Seconds(), allocBytes: heapAllocs() - allocs}
+	if t, ok := algo.(algorithm.Truncatable); ok {
+		r.truncated = t.Truncated()
+	}
+
+	return r, nil
+}
+
+// score estimates the spread of seeds, which r selected or which are the first seeds r selected,
+// under m.
+func (r *benchRun) score(ctx context.Context, graph *util.Graph, m model.Model, seeds set.Set) (BenchResult, error) {
+	spread, err := sample(ctx, m, set.NewSet(), seeds)
+	if err != nil {
+		return BenchResult{}, err
+	}
+
+	log.Printf("%s k=%d: %s in %.5fs \n", r.algorithm, seeds.Len(), spread, r.seconds)
+	return BenchResult{
+		Algorithm:  r.algorithm,
+		K:          seeds.Len(),
+		Mean:       spread.Mean,
+		StdErr:     spread.StdErr,
+		Lower:      spread.Lower,
+		Upper:      spread.Upper,
+		Seconds:    r.seconds,
+		AllocBytes: r.allocBytes,
+		Truncated:  r.truncated,
+		Seeds:      sortedLabels(graph, seeds),
+	}, nil
+}
+
+// Cumulative bytes allocated on the heap. Unlike runtime.ReadMemStats, reading it does not stop the
+// world, which would skew the timings.
+const heap_allocs_metric = "/gc/heap/allocs:bytes"
+
+// heapAllocs returns the bytes allocated on the heap since the program started.
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: heap_allocs_metric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return sample[0].Value.Uint64()
+}
+
+// sortedLabels returns the labels of the nodes of s in the order of the graph.
+func sortedLabels(graph *util.Graph, s set.Set) []string {
+	nodes := make([]util.Node, 0, s.Len())
+	for node := range s.Iter() {
+		nodes = append(nodes, node.(util.Node))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	labels := make([]string, len(nodes))
+	for i, u := range nodes {
+		labels[i] = graph.Label(u)
+	}
+	return labels
+}
@@ -0,0 +1,55 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jtejido/goim/util"
+	"strings"
+	"testing"
+)
+
+func TestBench(t *testing.T) {
+	config := &util.Config{Model: "ic", Seeds: 3, Seed: 1, Simulations: 100, Epsilon: 0.5, BenchAlgorithms: []string{"maxdegree", "celf", "tim"}, BenchFormat: "json"}
+	var buf bytes.Buffer
+	if err := Bench(context.Background(), config, testGraph(t), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []BenchResult
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 9 {
+		t.Fatalf("%d results, want 3 per algorithm", len(results))
+	}
+	for i, r := range results {
+		if want := strings.ToUpper(config.BenchAlgorithms[i/3]); r.Algorithm != want || r.K != i%3+1 || len(r.Seeds) != r.K {
+			t.Errorf("result %d: %s with k=%d and seeds %v, want %s with k=%d", i, r.Algorithm, r.K, r.Seeds, want, i%3+1)
+		}
+		if r.Mean < float64(r.K) {
+			t.Errorf("%s k=%d: spread %v below k", r.Algorithm, r.K, r.Mean)
+		}
+	}
+
+	// The rows of greedy algorithms are the prefixes of a single selection.
+	for _, rows := range [][]BenchResult{results[:3], results[3:6]} {
+		for k := 1; k < 3; k++ {
+			if rows[k].Seconds != rows[0].Seconds || rows[k].AllocBytes != rows[0].AllocBytes {
+				t.Errorf("%s k=%d: another selection than for k=1", rows[k].Algorithm, k+1)
+			}
+			seeds := make(map[string]bool)
+			for _, label := range rows[k].Seeds {
+				seeds[label] = true
+			}
+			for _, label := range rows[k-1].Seeds {
+				if !seeds[label] {
+					t.Errorf("%s: seeds %v for k=%d are not in %v for k=%d", rows[k].Algorithm, rows[k-1].Seeds, k, rows[k].Seeds, k+1)
+				}
+			}
+		}
+	}
+	if tim := results[6:]; tim[0].AllocBytes == 0 || tim[1].Seconds == tim[2].Seconds && tim[1].AllocBytes == tim[2].AllocBytes {
+		t.Errorf("TIM rows %+v do not come from a selection each", tim)
+	}
+}
@@ -2,11 +2,14 @@ package evaluator
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"github.com/jtejido/goim/algorithm"
 	"github.com/jtejido/goim/model"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -19,57 +22,143 @@ const (
 )
 
 type Evaluator struct {
-	config    *util.Config
-	graph     *util.Graph
-	algorithm algorithm.Algorithm
-	model     model.Model
-	writer    *bufio.Writer
+	config      *util.Config
+	graph       *util.Graph
+	algorithm   algorithm.Algorithm
+	evaluations []evaluation
+	output      string // name of the file writer writes to
+	writer      *bufio.Writer
 }
 
-func NewEvaluator(config *util.Config, graph *util.Graph, bufferedWriter *bufio.Writer) *Evaluator {
-	var algo algorithm.Algorithm
-	if util.ToAlgorithm(config.Algorithm) == util.CELF {
-		algo = algorithm.NewCELF(graph, config, INFLUENCE_MED)
-	} else if util.ToAlgorithm(config.Algorithm) == util.TIM {
-		algo = algorithm.NewTIM(graph, config, INFLUENCE_MED)
-	} else if util.ToAlgorithm(config.Algorithm) == util.DISCOUNT_DEGREE {
-		algo = algorithm.NewDiscountDegree(graph, config, INFLUENCE_MED)
-	} else if util.ToAlgorithm(config.Algorithm) == util.MAX_DEGREE {
-		algo = algorithm.NewMaxDegree(graph, config, INFLUENCE_MED)
-	} else if util.ToAlgorithm(config.Algorithm) == util.PMC {
-		algo = algorithm.NewPMC(graph, config, INFLUENCE_MED)
+// evaluation is a diffusion model seeds are scored under. The first one also realizes the
+// diffusion that activates nodes between trials.
+type evaluation struct {
+	name  string
+	model model.Model
+}
+
+// NewEvaluator returns an evaluator writing each trial to bufferedWriter, which writes to the file output.
+func NewEvaluator(config *util.Config, graph *util.Graph, output string, bufferedWriter *bufio.Writer) (*Evaluator, error) {
+	algo, err := algorithm.New(config.Algorithm, graph, config, INFLUENCE_MED)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluations, err := newEvaluations(config, graph)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator{config, graph, algo, evaluations, output, bufferedWriter}, nil
+}
+
+// newEvaluations builds the configured evaluation models, loading the graphs of those that use
+// a different probability file than the selection graph. These files share the format of the graph
+// file but keep their own probabilities.
+func newEvaluations(config *util.Config, graph *util.Graph) ([]evaluation, error) {
+	graphs := map[string]*util.Graph{"": graph, config.GraphPath: graph}
+	opts := config.ReadOptions()
+	opts.Weighting = ""
+	evaluations := make([]evaluation, 0)
+	for _, spec := range config.EvaluationModels() {
+		name, graphPath := util.SplitModelSpec(spec)
+		g, ok := graphs[graphPath]
+		if !ok {
+			var err error
+			if g, err = util.NewGraph(graphPath, opts); err != nil {
+				return nil, err
+			}
+			if !g.SameNodes(graph) {
+				return nil, fmt.Errorf("%s: evaluation graph has other nodes than %s", graphPath, config.GraphPath)
+			}
+			graphs[graphPath] = g
+		}
+
+		m, err := model.New(name, g, config, INFLUENCE_MED)
+		if err != nil {
+			return nil, err
+		}
+
+		evaluations = append(evaluations, evaluation{spec, m})
 	}
 
-	var m model.Model
-	if util.ToDiffusionModel(config.Model) == util.IC {
-		m = model.NewIndependentCascade(graph, config, INFLUENCE_MED)
-	} else if util.ToDiffusionModel(config.Model) == util.LT {
-		m = model.NewLinearThreshold(graph, config, INFLUENCE_MED)
+	return evaluations, nil
+}
+
+// sample estimates the spread of seeds under m, stopping with ctx.Err() once ctx is done if m is a
+// model.ContextEstimator.
+func sample(ctx context.Context, m model.Model, activated, seeds set.Set) (util.Spread, error) {
+	if ce, ok := m.(model.ContextEstimator); ok {
+		return ce.SampleContext(ctx, activated, seeds)
 	}
 
-	return &Evaluator{config, graph, algo, m, bufferedWriter}
+	return m.Sample(activated, seeds), ctx.Err()
 }
 
-func (e *Evaluator) Run() error {
+// EvaluateSeeds estimates the spread of a given seed set under each evaluation model, without
+// running any seed-selection algorithm.
+func EvaluateSeeds(config *util.Config, graph *util.Graph, seeds set.Set, bufferedWriter *bufio.Writer) error {
+	evaluations, err := newEvaluations(config, graph)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Seeds: %d \n", seeds.Len())
+	t0 := makeTimestamp()
+	for _, ev := range evaluations {
+		spread := ev.model.Sample(set.NewSet(), seeds)
+		log.Printf("Estimated spread (%s): %s \n", ev.name, spread)
+		util.LogSpread(graph, ev.name, seeds, spread, bufferedWriter)
+	}
+	t1 := makeTimestamp()
+
+	log.Printf("Time elapsed: %.5f \n", float64(t1-t0)/(1000.0*60.0))
+	return bufferedWriter.Flush()
+}
+
+// Run selects and scores seeds for each trial. It stops with ctx.Err() once ctx is done.
+func (e *Evaluator) Run(ctx context.Context) error {
 	activated := set.NewSet()
 	var roundtime, timetotal float64
-	log.Printf("Algorithm: %s \n", util.ToAlgorithm(e.config.Algorithm).String())
-	log.Printf("Model: %s \n", util.ToDiffusionModel(e.config.Model).String())
-	log.Printf("Output: %s", e.config.LogFileName())
+	log.Printf("Algorithm: %s \n", strings.ToUpper(e.config.Algorithm))
+	log.Printf("Selection model: %s \n", strings.ToUpper(e.config.SelectionModelName()))
+	for _, ev := range e.evaluations {
+		log.Printf("Evaluation model: %s \n", ev.name)
+	}
+	log.Printf("Output: %s", e.output)
 	for stage := 1; stage <= e.config.Trials; stage++ {
 		t0 := makeTimestamp()
-		seeds := e.algorithm.Select(activated)
-		diffusion := e.model.Diffuse(seeds)
+		seeds, err := e.algorithm.Select(ctx, activated)
+		if err != nil {
+			return err
+		}
+		t1 := makeTimestamp()
+		if t, ok := e.algorithm.(algorithm.Truncatable); ok && t.Truncated() {
+			log.Printf("Selection truncated by its time or memory budget with %d seeds \n", seeds.Len())
+		}
+
+		spreads := make([]util.Spread, len(e.evaluations))
+		for i, ev := range e.evaluations {
+			if spreads[i], err = sample(ctx, ev.model, activated, seeds); err != nil {
+				return err
+			}
+			log.Printf("Estimated spread (%s): %s \n", ev.name, spreads[i])
+		}
+		diffusion := e.evaluations[0].model.Diffuse(seeds)
 
 		for node := range diffusion.Iter() {
 			activated.Add(node.(util.Node))
 		}
 
-		t1 := makeTimestamp()
-
 		timetotal += float64(t1-t0) / (1000.0 * 60.0)
 		roundtime = float64(t1-t0) / (1000.0 * 60.0)
-		util.LogSeed(stage, activated.Len(), roundtime, timetotal, seeds, e.config, e.writer)
+		approx := -1.
+		if c, ok := e.algorithm.(algorithm.Certified); ok {
+			approx = c.Approximation()
+			log.Printf("Approximation ratio (lower bound): %.5f \n", approx)
+		}
+
+		util.LogSeed(e.graph, stage, activated.Len(), roundtime, timetotal, seeds, spreads, approx, e.config, e.writer)
 		if err := e.writer.Flush(); err != nil {
 			return err
 		}
@@ -0,0 +1,177 @@
+package evaluator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testGraph returns the circulant graph of 50 nodes in testdata, each with 3 out-edges of
+// probability 0.2.
+func testGraph(t *testing.T) *util.Graph {
+	t.Helper()
+	g, err := util.NewGraph("../testdata/circulant.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+// evaluateSeeds runs EvaluateSeeds and returns the fields of each line it writes.
+func evaluateSeeds(t *testing.T, config *util.Config, g *util.Graph, seeds set.Set) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EvaluateSeeds(config, g, seeds, bufio.NewWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		lines = append(lines, strings.Split(line, "\t"))
+	}
+
+	return lines
+}
+
+func TestEvaluateSeeds(t *testing.T) {
+	g := testGraph(t)
+	config := &util.Config{Model: "ic", EvaluationModel: []string{"ic", "lt"}, Seed: 1, Simulations: 500}
+	seeds := set.NewSet(util.Node(0), util.Node(25))
+	lines := evaluateSeeds(t, config, g, seeds)
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want one per evaluation model", len(lines))
+	}
+	for i, name := range config.EvaluationModel {
+		// model, seeds, mean, standard error, confidence interval and samples
+		fields := lines[i]
+		if len(fields) != 7 || fields[0] != name || fields[6] != "500" {
+			t.Errorf("line %d: %q", i, fields)
+			continue
+		}
+		if fields[1] != "[0, 25]" && fields[1] != "[25, 0]" {
+			t.Errorf("%s: seeds %s", name, fields[1])
+		}
+		if mean, err := strconv.ParseFloat(fields[2], 64); err != nil || mean < 2 || mean > 50 {
+			t.Errorf("%s: mean spread %s", name, fields[2])
+		}
+	}
+
+	// Unknown models fail before any simulation.
+	config.EvaluationModel = []string{"ic", "sir"}
+	if err := EvaluateSeeds(config, g, seeds, bufio.NewWriter(&bytes.Buffer{})); err == nil {
+		t.Error("EvaluateSeeds under model sir did not fail")
+	}
+}
+
+func TestRunLogsOutput(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	config := &util.Config{Algorithm: "maxdegree", Model: "ic", Seeds: 2, Trials: 1, Seed: 1, Simulations: 100}
+	var buf bytes.Buffer
+	e, err := NewEvaluator(config, testGraph(t), "out/run.txt", bufio.NewWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "Output: out/run.txt\n") {
+		t.Errorf("logged %q, want the output file given to NewEvaluator", logged.String())
+	}
+	if buf.Len() == 0 {
+		t.Error("Run wrote nothing")
+	}
+}
+
+// writeGraph writes the circulant graph of testdata with every probability set to p and returns its path.
+func writeGraph(t *testing.T, p string) string {
+	t.Helper()
+	data, err := os.ReadFile("../testdata/circulant.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "circulant_"+p+".txt")
+	if err := os.WriteFile(path, bytes.ReplaceAll(data, []byte(" 0.2\n"), []byte(" "+p+"\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSelectionModel(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	config := &util.Config{Algorithm: "celf", Model: "ic", SelectionModel: "lt", Seeds: 2, Trials: 1, Seed: 1, Simulations: 100}
+	var buf bytes.Buffer
+	e, err := NewEvaluator(config, testGraph(t), "run.txt", bufio.NewWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.evaluations) != 1 {
+		t.Fatalf("%d evaluation models, want 1", len(e.evaluations))
+	}
+	if _, ok := e.evaluations[0].model.(*model.IndependentCascade); !ok {
+		t.Errorf("seeds selected under LT are scored under %T, want IC", e.evaluations[0].model)
+	}
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "Selection model: LT") || !strings.Contains(logged.String(), "Evaluation model: ic") {
+		t.Errorf("logged %q", logged.String())
+	}
+}
+
+func TestEvaluationGraph(t *testing.T) {
+	g := testGraph(t)
+	seeds := set.NewSet(util.Node(0))
+	dense := writeGraph(t, "0.9")
+
+	// Evaluation graphs keep their own probabilities whatever Config.Weighting.
+	config := &util.Config{Model: "ic", Weighting: "const:0.01", EvaluationModel: []string{"ic", "ic:" + dense, "lt"}, Seed: 1, Simulations: 500}
+	lines := evaluateSeeds(t, config, g, seeds)
+	if len(lines) != 3 {
+		t.Fatalf("wrote %d lines, want one per evaluation model", len(lines))
+	}
+
+	var means []float64
+	for i, spec := range config.EvaluationModel {
+		mean, err := strconv.ParseFloat(lines[i][2], 64)
+		if lines[i][0] != spec || err != nil {
+			t.Fatalf("line %d: %q", i, lines[i])
+		}
+		means = append(means, mean)
+	}
+	if means[0] > 5 || means[1] < 40 {
+		t.Errorf("spread %v on the testdata graph and %v on its denser copy", means[0], means[1])
+	}
+}
+
+func TestEvaluationGraphNodes(t *testing.T) {
+	g := testGraph(t)
+	other := filepath.Join(t.TempDir(), "other.txt")
+	os.WriteFile(other, []byte("0 1 0.5\n1 50 0.5\n"), 0o644)
+	config := &util.Config{Model: "ic", GraphPath: "circulant.txt", EvaluationModel: []string{"ic", "ic:" + other}}
+	if _, err := newEvaluations(config, g); err == nil || !strings.Contains(err.Error(), "other nodes than circulant.txt") {
+		t.Errorf("newEvaluations = %v, want an error on the nodes of %s", err, other)
+	}
+
+	config.EvaluationModel = []string{"ic", "ic:" + filepath.Join(t.TempDir(), "missing.txt")}
+	if _, err := newEvaluations(config, g); err == nil {
+		t.Error("newEvaluations of a missing graph file did not fail")
+	}
+}
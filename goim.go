@@ -0,0 +1,312 @@
+// Package goim selects seed sets that maximize influence spread in social graphs and estimates the
+// spread of seed sets, for use from other Go programs. The goim command in cmd/goim is built on it.
+//
+// Algorithms and diffusion models are looked up by name in the registries of the algorithm and model
+// packages, see algorithm.Registered and model.Registered.
+package goim
+
+import (
+	"context"
+	"fmt"
+	"github.com/jtejido/goim/algorithm"
+	"github.com/jtejido/goim/model"
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	default_model       = model.IC
+	default_simulations = 10000
+)
+
+// Graph is an influence graph, a directed graph whose edges carry influence probabilities.
+type Graph struct {
+	g *util.Graph
+}
+
+// NumNodes returns the number of nodes of g.
+func (g *Graph) NumNodes() int {
+	return g.g.NumNodes()
+}
+
+// NumEdges returns the number of edges of g.
+func (g *Graph) NumEdges() int {
+	return g.g.NumEdges()
+}
+
+// LoadOptions configures how LoadGraph parses an edge list. The zero value reads the "u v p_uv"
+// lines of the bundled graph files.
+type LoadOptions struct {
+	// Edge weighting replacing the probabilities of the file, in which case "u v" lines suffice:
+	// "const:p", "wc", "tv", "uniform" or "random".
+	Weighting string
+	// Seed of the random weightings.
+	Seed int64
+	// Probability of the edges of "u v" lines, which are rejected when unset.
+	DefaultProbability float64
+	// Characters starting comment lines, such as "#%".
+	Comments string
+	// Number of header lines to skip.
+	HeaderLines int
+	// Field delimiter: "tab", "comma" or a single character, whitespace when empty.
+	Delimiter string
+	// Add the reverse of every edge, for undirected edge lists.
+	Undirected bool
+}
+
+// Options tunes seed selection and spread estimation. Zero fields take the defaults of config.toml.
+type Options struct {
+	// Diffusion model SelectSeeds optimizes for, "ic" when empty.
+	Model string
+	// Seed of the random number generators.
+	Seed int64
+	// Monte Carlo simulations per spread estimate, 10000 when unset.
+	Simulations int
+	// Target relative half-width of the 95% confidence interval, simulations are repeated in
+	// batches until it is reached or MaxSimulations have been run.
+	Precision      float64
+	MaxSimulations int
+	// Approximation error, failure exponent and failure probability of the RR-set algorithms.
+	Epsilon float64
+	Ell     float64
+	Delta   float64
+	// Time budget of SelectSeeds, once over it returns the best seeds found so far.
+	TimeLimit time.Duration
+	// Number of live-edge snapshots of PMC.
+	Snapshots int
+	// Goroutines used by parallel algorithms and simulations, GOMAXPROCS when unset.
+	Workers int
+	// Memory in MiB the RR sets may use, unlimited when unset. Selections needing more fail with
+	// algorithm.ErrMemoryBudget unless MemoryPolicy is "truncate".
+	MemoryBudget int
+	MemoryPolicy string
+	// File TIM saves its RR sets to and reuses them from, see SelectSeeds.
+	RRSetsFile string
+}
+
+// Result is the outcome of a seed selection.
+type Result struct {
+	// Labels of the selected seeds in the graph file, in the order of the graph's nodes.
+	Seeds []string
+	// Lower bound on the approximation ratio, set when Certified.
+	Approximation float64
+	Certified     bool
+	// Whether the selection was cut short by Options.TimeLimit.
+	Truncated bool
+	Elapsed   time.Duration
+}
+
+// Spread is a Monte Carlo estimate of the expected number of nodes a seed set reaches.
+type Spread struct {
+	Mean     float64
+	Variance float64
+	StdErr   float64
+	// 95% confidence interval of the mean.
+	Lower   float64
+	Upper   float64
+	Samples int
+}
+
+// LoadGraph reads an influence graph from r.
+func LoadGraph(r io.Reader, opts *LoadOptions) (_ *Graph, err error) {
+	defer recoverError(&err)
+	if r == nil {
+		return nil, fmt.Errorf("goim: nil reader")
+	}
+
+	if opts == nil {
+		opts = new(LoadOptions)
+	}
+
+	g, err := util.ReadGraph(r, &util.ReadOptions{
+		Weighting:          opts.Weighting,
+		Seed:               opts.Seed,
+		DefaultProbability: opts.DefaultProbability,
+		Comments:           opts.Comments,
+		HeaderLines:        opts.HeaderLines,
+		Delimiter:          opts.Delimiter,
+		Undirected:         opts.Undirected,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if g.NumNodes() == 0 {
+		return nil, fmt.Errorf("goim: graph has no edges")
+	}
+
+	return &Graph{g}, nil
+}
+
+// LoadBinaryGraph loads a graph file written by WriteBinary or the convert command, memory-mapped
+// where the platform allows it.
+func LoadBinaryGraph(path string) (_ *Graph, err error) {
+	defer recoverError(&err)
+	g, err := util.ReadBinaryGraph(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Graph{g}, nil
+}
+
+// WriteBinary writes g to w in the binary format read by LoadBinaryGraph.
+func (g *Graph) WriteBinary(w io.Writer) error {
+	return g.g.WriteBinary(w)
+}
+
+// SelectSeeds selects k seeds of graph with the named algorithm. It returns ctx.Err() once ctx is done.
+// With Options.RRSetsFile set, TIM reuses the RR sets an earlier call saved there for the same graph,
+// model and seed, so selecting again for another k mostly skips sampling.
+func SelectSeeds(ctx context.Context, graph *Graph, algorithmName string, k int, opts *Options) (res Result, err error) {
+	defer recoverError(&err)
+	if graph == nil {
+		return res, fmt.Errorf("goim: nil graph")
+	}
+	if k <= 0 || k > graph.NumNodes() {
+		return res, fmt.Errorf("goim: k must be in [1, %d], got %d", graph.NumNodes(), k)
+	}
+
+	config, err := newConfig(opts)
+	if err != nil {
+		return res, err
+	}
+
+	config.Algorithm = algorithmName
+	config.Seeds = k
+	algo, err := algorithm.New(algorithmName, graph.g, config, 0)
+	if err != nil {
+		return res, err
+	}
+
+	t0 := time.Now()
+	seeds, err := algo.Select(ctx, set.NewSet())
+	res.Elapsed = time.Since(t0)
+	if err != nil {
+		return res, err
+	}
+
+	res.Seeds = sortedSeeds(graph.g, seeds)
+	if t, ok := algo.(algorithm.Truncatable); ok {
+		res.Truncated = t.Truncated()
+	}
+	if c, ok := algo.(algorithm.Certified); ok {
+		res.Approximation = c.Approximation()
+		res.Certified = true
+	}
+
+	return res, nil
+}
+
+// EstimateSpread estimates the spread of seeds, given by their labels in the graph file, in graph under
+// the named diffusion model. The simulations stop once ctx is done, with ctx.Err().
+func EstimateSpread(ctx context.Context, graph *Graph, modelName string, seeds []string, opts *Options) (spread Spread, err error) {
+	defer recoverError(&err)
+	if graph == nil {
+		return spread, fmt.Errorf("goim: nil graph")
+	}
+
+	config, err := newConfig(opts)
+	if err != nil {
+		return spread, err
+	}
+
+	m, err := model.New(modelName, graph.g, config, 0)
+	if err != nil {
+		return spread, err
+	}
+
+	s := set.NewSet()
+	for _, u := range seeds {
+		node, ok := graph.g.Lookup(u)
+		if !ok {
+			return spread, fmt.Errorf("goim: seed %q is not in the graph", u)
+		}
+
+		s.Add(node)
+	}
+	if err := ctx.Err(); err != nil {
+		return spread, err
+	}
+
+	var est util.Spread
+	if ce, ok := m.(model.ContextEstimator); ok {
+		if est, err = ce.SampleContext(ctx, set.NewSet(), s); err != nil {
+			return spread, err
+		}
+	} else {
+		est = m.Sample(set.NewSet(), s)
+		if err := ctx.Err(); err != nil {
+			return spread, err
+		}
+	}
+
+	return Spread{est.Mean, est.Variance, est.StdErr, est.Lower, est.Upper, est.Samples}, nil
+}
+
+// newConfig validates opts and maps it onto the configuration read by the algorithms and models.
+func newConfig(opts *Options) (*util.Config, error) {
+	if opts == nil {
+		opts = new(Options)
+	}
+	if opts.Simulations < 0 || opts.MaxSimulations < 0 || opts.Snapshots < 0 || opts.Workers < 0 || opts.MemoryBudget < 0 {
+		return nil, fmt.Errorf("goim: negative simulation, snapshot, worker count or memory budget")
+	}
+	if p := strings.ToLower(opts.MemoryPolicy); p != "" && p != "error" && p != "truncate" {
+		return nil, fmt.Errorf("goim: unknown memory policy %q", opts.MemoryPolicy)
+	}
+	if opts.Epsilon < 0 || opts.Delta < 0 || opts.Delta >= 1 || opts.Precision < 0 || opts.TimeLimit < 0 {
+		return nil, fmt.Errorf("goim: epsilon, precision and time limit must not be negative and delta must be in [0, 1)")
+	}
+
+	config := &util.Config{
+		Trials:         1,
+		Model:          strings.ToLower(opts.Model),
+		Seed:           opts.Seed,
+		Simulations:    opts.Simulations,
+		Precision:      opts.Precision,
+		MaxSimulations: opts.MaxSimulations,
+		Epsilon:        opts.Epsilon,
+		Ell:            opts.Ell,
+		Delta:          opts.Delta,
+		TimeLimit:      opts.TimeLimit.Seconds(),
+		Snapshots:      opts.Snapshots,
+		Workers:        opts.Workers,
+		MemoryBudget:   opts.MemoryBudget,
+		MemoryPolicy:   opts.MemoryPolicy,
+		RRSetsFile:     opts.RRSetsFile,
+	}
+	if config.Model == "" {
+		config.Model = default_model
+	}
+	if config.Simulations == 0 {
+		config.Simulations = default_simulations
+	}
+
+	return config, nil
+}
+
+// recoverError turns a panic of the loading, selection or estimation into an error. Panics of their worker
+// goroutines are raised again in the calling one by util.Parallel.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("goim: %v", r)
+	}
+}
+
+func sortedSeeds(g *util.Graph, s set.Set) []string {
+	nodes := make([]util.Node, 0, s.Len())
+	for node := range s.Iter() {
+		nodes = append(nodes, node.(util.Node))
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i] < nodes[j] })
+
+	seeds := make([]string, len(nodes))
+	for i, u := range nodes {
+		seeds[i] = g.Label(u)
+	}
+	return seeds
+}
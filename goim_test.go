@@ -0,0 +1,173 @@
+package goim
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testGraph returns the circulant graph of 50 nodes in testdata, each with 3 out-edges.
+func testGraph(t *testing.T) *Graph {
+	t.Helper()
+	f, err := os.Open("testdata/circulant.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	g, err := LoadGraph(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestLoadGraph(t *testing.T) {
+	g := testGraph(t)
+	if g.NumNodes() != 50 || g.NumEdges() != 150 {
+		t.Errorf("got %d nodes and %d edges, want 50 and 150", g.NumNodes(), g.NumEdges())
+	}
+
+	for _, input := range []string{"", "a b\n", "a b x\n"} {
+		if _, err := LoadGraph(strings.NewReader(input), nil); err == nil {
+			t.Errorf("LoadGraph(%q) did not fail", input)
+		}
+	}
+	if _, err := LoadGraph(nil, nil); err == nil {
+		t.Errorf("LoadGraph(nil) did not fail")
+	}
+
+	g, err := LoadGraph(strings.NewReader("a b\nb c\n"), &LoadOptions{Weighting: "wc"})
+	if err != nil || g.NumEdges() != 2 {
+		t.Errorf("LoadGraph with a weighting = %v, %v", g, err)
+	}
+}
+
+func TestLoadBinaryGraph(t *testing.T) {
+	g := testGraph(t)
+	var buf bytes.Buffer
+	if err := g.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	data := buf.Bytes()
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)-1] ^= 0xff
+	for name, b := range map[string][]byte{"ok": data, "truncated": data[:len(data)-8], "corrupted": flipped} {
+		path := filepath.Join(dir, name+".goim")
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := LoadBinaryGraph(path)
+		if name == "ok" {
+			if err != nil || h.NumNodes() != 50 || h.NumEdges() != 150 {
+				t.Errorf("LoadBinaryGraph = %v, %v, want the graph written", h, err)
+			}
+		} else if err == nil || h != nil {
+			t.Errorf("LoadBinaryGraph of a %s file = %v, %v, want an error", name, h, err)
+		}
+	}
+}
+
+func TestSelectSeeds(t *testing.T) {
+	g := testGraph(t)
+	ctx := context.Background()
+	for _, name := range []string{"maxdegree", "imm", "opimc", "pmc"} {
+		res, err := SelectSeeds(ctx, g, name, 3, &Options{Seed: 1, Simulations: 100, Snapshots: 20, Epsilon: 0.5})
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(res.Seeds) != 3 {
+			t.Errorf("%s: got seeds %v, want 3", name, res.Seeds)
+		}
+		for _, s := range res.Seeds {
+			if _, ok := g.g.Lookup(s); !ok {
+				t.Errorf("%s: seed %q is not a label of the graph", name, s)
+			}
+		}
+		if res.Certified != (name == "opimc") {
+			t.Errorf("%s: Certified = %t", name, res.Certified)
+		}
+	}
+}
+
+func TestSelectSeedsErrors(t *testing.T) {
+	g := testGraph(t)
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		graph *Graph
+		algo  string
+		k     int
+		opts  *Options
+	}{
+		{"nil graph", nil, "imm", 3, nil},
+		{"k zero", g, "imm", 0, nil},
+		{"k too large", g, "imm", 51, nil},
+		{"unknown algorithm", g, "greedy", 3, nil},
+		{"unknown model", g, "imm", 3, &Options{Model: "sir"}},
+		{"negative simulations", g, "celf", 3, &Options{Simulations: -1}},
+		{"delta out of range", g, "ssa", 3, &Options{Delta: 1}},
+		{"unknown memory policy", g, "tim", 3, &Options{MemoryPolicy: "spill"}},
+	}
+	for _, tt := range tests {
+		if _, err := SelectSeeds(ctx, tt.graph, tt.algo, tt.k, tt.opts); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestSelectSeedsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SelectSeeds(ctx, testGraph(t), "imm", 3, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestEstimateSpread(t *testing.T) {
+	g := testGraph(t)
+	ctx := context.Background()
+	opts := &Options{Seed: 1, Simulations: 2000}
+	for _, model := range []string{"ic", "lt"} {
+		spread, err := EstimateSpread(ctx, g, model, []string{"0", "25"}, opts)
+		if err != nil {
+			t.Errorf("%s: %v", model, err)
+			continue
+		}
+		if spread.Samples != 2000 || spread.Mean < 2 || spread.Mean > 50 || spread.Lower > spread.Mean || spread.Upper < spread.Mean {
+			t.Errorf("%s: implausible spread %+v", model, spread)
+		}
+	}
+
+	if _, err := EstimateSpread(ctx, g, "ic", []string{"0", "x"}, opts); err == nil {
+		t.Errorf("unknown seed: no error")
+	}
+	if _, err := EstimateSpread(ctx, g, "sir", []string{"0"}, opts); err == nil {
+		t.Errorf("unknown model: no error")
+	}
+	if _, err := EstimateSpread(ctx, nil, "ic", []string{"0"}, opts); err == nil {
+		t.Errorf("nil graph: no error")
+	}
+}
+
+func TestEstimateSpreadCancelled(t *testing.T) {
+	g := testGraph(t)
+	for _, model := range []string{"ic", "lt"} {
+		// Cancelled while simulating: the estimate stops early rather than running every simulation.
+		ctx, cancel := context.WithCancel(context.Background())
+		go cancel()
+		_, err := EstimateSpread(ctx, g, model, []string{"0"}, &Options{Simulations: 1 << 24})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: got %v, want context.Canceled", model, err)
+		}
+	}
+}
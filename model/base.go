@@ -1,14 +1,41 @@
 package model
 
 import (
+	"context"
+	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 )
 
 type Model interface {
+	SpreadEstimator
 	Diffuse(seeds set.Set) set.Set
 	Type() int
 }
 
+// SpreadEstimator estimates, by Monte Carlo simulation, the expected number of nodes outside
+// activated that seeds reach.
+type SpreadEstimator interface {
+	Sample(activated, seeds set.Set) util.Spread
+	// SampleWith returns the spread of seeds and of seeds with extra, measured on the same simulations.
+	SampleWith(activated, seeds set.Set, extra util.Node) (float64, float64)
+}
+
+// ContextEstimator is a SpreadEstimator whose estimates can be cancelled.
+type ContextEstimator interface {
+	// SampleContext is Sample stopping once ctx is done, with the estimate of the simulations run so far
+	// and ctx.Err().
+	SampleContext(ctx context.Context, activated, seeds set.Set) (util.Spread, error)
+	// SampleWithContext is SampleWith stopping once ctx is done, with the estimates of the simulations
+	// run so far and ctx.Err().
+	SampleWithContext(ctx context.Context, activated, seeds set.Set, extra util.Node) (float64, float64, error)
+}
+
+// RRSampler generates reverse-reachable sets, the nodes that reach root in a random
+// realization of the diffusion, root included.
+type RRSampler interface {
+	RRSet(root util.Node) []util.Node
+}
+
 type base struct {
 	t int
 }
@@ -16,3 +43,35 @@ type base struct {
 func (b *base) Type() int {
 	return b.t
 }
+
+// estimate runs batches of Config.Simulations until the relative half-width of the 95% confidence
+// interval drops to Config.Precision, or Config.MaxSimulations (100 batches when unset) is reached.
+// Without a precision a single batch is run. batch returns the moments of its outcomes, it may stop
+// early once ctx is done, in which case estimate returns with ctx.Err().
+func estimate(ctx context.Context, config *util.Config, batch func(samples int) util.Moments) (util.Spread, error) {
+	maxSimulations := config.MaxSimulations
+	if maxSimulations <= 0 {
+		maxSimulations = 100 * config.Simulations
+	}
+
+	var m util.Moments
+	for {
+		m.Merge(batch(config.Simulations))
+		spread := util.NewSpread(m)
+		if err := ctx.Err(); err != nil {
+			return spread, err
+		}
+		if config.Precision <= 0 || spread.RelativeHalfWidth() <= config.Precision || m.N >= maxSimulations {
+			return spread, nil
+		}
+	}
+}
+
+func toNodes(s set.Set) []util.Node {
+	nodes := make([]util.Node, 0, s.Len())
+	for node := range s.Iter() {
+		nodes = append(nodes, node.(util.Node))
+	}
+
+	return nodes
+}
@@ -1,70 +1,182 @@
 package model
 
 import (
+	"context"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 	"github.com/lucky-se7en/grand"
 	"github.com/lucky-se7en/grand/source64"
+	"sync/atomic"
 )
 
+// IndependentCascade simulates the Independent Cascade model. Sample and SampleWith may be called
+// from several goroutines, RRSet, Trial and Diffuse share one generator and trial list and may not.
 type IndependentCascade struct {
 	base
 	graph   *util.Graph
 	config  *util.Config
 	random  *grand.Rand
 	samples int
+	calls   int64
 	trials  []util.TrialType
 }
 
+const (
+	// Number of simulations sharing one random stream in Sample.
+	simulation_block = 64
+)
+
+func init() {
+	Register(Registration{
+		Name:        IC,
+		Description: "Independent Cascade, each newly activated node gets one chance to activate each out-neighbor with the edge probability",
+		New: func(graph *util.Graph, config *util.Config, t int) (Model, error) {
+			return NewIndependentCascade(graph, config, t), nil
+		},
+		Options: []util.Option{
+			{Key: "simulations", Description: "Monte Carlo simulations per spread estimate"},
+			{Key: "precision", Description: "target relative half-width of the 95% confidence interval"},
+			{Key: "maxSimulations", Description: "simulation cap when precision is set"},
+			{Key: "workers", Description: "goroutines running the simulations"},
+		},
+	})
+}
+
 func NewIndependentCascade(graph *util.Graph, config *util.Config, t int) *IndependentCascade {
 	ret := &IndependentCascade{graph: graph, config: config, random: grand.New(source64.NewXoShiRo256StarStar(config.Seed)), trials: make([]util.TrialType, 0)}
 	ret.t = t
 	return ret
 }
 
-func (ic *IndependentCascade) Sample(activated, seeds set.Set) float64 {
-	return ic.sample(activated, seeds, false, false)
+func (ic *IndependentCascade) Sample(activated, seeds set.Set) util.Spread {
+	spread, _ := ic.SampleContext(context.Background(), activated, seeds)
+	return spread
 }
 
-func (ic *IndependentCascade) Trial(activated, seeds set.Set, inv bool) float64 {
-	return ic.sample(activated, seeds, true, inv)
+// SampleContext checks ctx before each block of simulation_block simulations.
+func (ic *IndependentCascade) SampleContext(ctx context.Context, activated, seeds set.Set) (util.Spread, error) {
+	sources := toNodes(seeds)
+	return estimate(ctx, ic.config, func(samples int) util.Moments {
+		m, _ := ic.simulate(ctx, samples, func(random *grand.Rand) (float64, float64) {
+			queue, active := ic.start(sources)
+			return ic.propagate(activated, queue, active, random, false, false), 0
+		})
+
+		return m
+	})
 }
 
 func (ic *IndependentCascade) Trials() []util.TrialType {
 	return ic.trials
 }
 
-func (ic *IndependentCascade) sample(activated, seeds set.Set, trial, inv bool) float64 {
-	var outspread float64
+// RRSet flips the in-edges reached backwards from root.
+func (ic *IndependentCascade) RRSet(root util.Node) []util.Node {
+	rr := []util.Node{root}
+	seeds := set.NewSet()
+	seeds.Add(root)
+	ic.Trial(set.NewSet(), seeds, true)
+	for _, tt := range ic.trials {
+		if tt.Trial == 1 {
+			rr = append(rr, tt.Target)
+		}
+	}
+
+	return rr
+}
+
+func (ic *IndependentCascade) Trial(activated, seeds set.Set, inv bool) float64 {
 	ic.trials = make([]util.TrialType, 0)
-	samples := ic.config.Simulations
-	if trial {
-		samples = 1
+	queue, active := ic.start(toNodes(seeds))
+	return ic.propagate(activated, queue, active, ic.random, true, inv)
+}
+
+// SampleWith estimates the spread of seeds and, continuing each simulation in the same realization,
+// the spread of seeds together with extra.
+func (ic *IndependentCascade) SampleWith(activated, seeds set.Set, extra util.Node) (float64, float64) {
+	outspread, withExtra, _ := ic.SampleWithContext(context.Background(), activated, seeds, extra)
+	return outspread, withExtra
+}
+
+// SampleWithContext checks ctx before each block of simulation_block simulations.
+func (ic *IndependentCascade) SampleWithContext(ctx context.Context, activated, seeds set.Set, extra util.Node) (float64, float64, error) {
+	sources := toNodes(seeds)
+	outspread, withExtra := ic.simulate(ctx, ic.config.Simulations, func(random *grand.Rand) (float64, float64) {
+		queue, active := ic.start(sources)
+		reached := ic.propagate(activated, queue, active, random, false, false)
+		more := 0.
+		if !active.Contains(extra) {
+			queue.Push(extra)
+			active.Add(extra)
+			more = ic.propagate(activated, queue, active, random, false, false)
+		}
+
+		return reached, reached + more
+	})
+	if outspread.N == 0 {
+		return 0, 0, ctx.Err()
 	}
 
-	for sample := 1; sample <= samples; sample++ {
-		var reached_round float64
-		queue := util.NewQueue()
-		active := set.NewSet()
-		for source := range seeds.Iter() {
-			ss := source.(util.Node)
-			queue.Push(ss)
-			active.Add(ss)
+	return outspread.Mean, withExtra / float64(outspread.N), ctx.Err()
+}
+
+// simulate runs sim samples times across Config.Workers goroutines and returns the moments of the first
+// value it reports and the sum of the second. Simulations are grouped in fixed blocks, each with its own
+// stream derived from the seed, the call and the block index, and merged in block order, so results do
+// not depend on the number of workers. Blocks are skipped once ctx is done.
+func (ic *IndependentCascade) simulate(ctx context.Context, samples int, sim func(random *grand.Rand) (float64, float64)) (util.Moments, float64) {
+	call := atomic.AddInt64(&ic.calls, 1)
+	blocks := (samples + simulation_block - 1) / simulation_block
+	moments := make([]util.Moments, blocks)
+	sums := make([]float64, blocks)
+	util.Parallel(ic.config.NumWorkers(), blocks, func(_, b int) {
+		if ctx.Err() != nil {
+			return
 		}
 
-		for queue.Len() > 0 {
-			node_id := queue.Peek().(util.Node)
-			ic.sampleOutGoingEdges(node_id, queue, active, trial, inv)
-			queue.Pop()
-			if !activated.Contains(node_id) {
-				reached_round++
-			}
+		random := grand.New(source64.NewXoShiRo256StarStar(util.DeriveSeed(ic.config.Seed, call, int64(b))))
+		for i := b * simulation_block; i < samples && i < (b+1)*simulation_block; i++ {
+			x, y := sim(random)
+			moments[b].Add(x)
+			sums[b] += y
+		}
+	})
+
+	var m util.Moments
+	var y float64
+	for b := range moments {
+		m.Merge(moments[b])
+		y += sums[b]
+	}
+
+	return m, y
+}
+
+func (ic *IndependentCascade) start(sources []util.Node) (*util.Queue, set.Set) {
+	queue := util.NewQueue()
+	active := set.NewUnsafeSet()
+	for _, ss := range sources {
+		if active.Add(ss) {
+			queue.Push(ss)
 		}
+	}
+
+	return queue, active
+}
 
-		outspread += reached_round
+// propagate runs the cascade until queue is empty and returns the number of reached nodes outside activated.
+func (ic *IndependentCascade) propagate(activated set.Set, queue *util.Queue, active set.Set, random *grand.Rand, trial, inv bool) float64 {
+	var reached float64
+	for queue.Len() > 0 {
+		node_id := queue.Peek().(util.Node)
+		ic.sampleOutGoingEdges(node_id, queue, active, random, trial, inv)
+		queue.Pop()
+		if !activated.Contains(node_id) {
+			reached++
+		}
 	}
 
-	return outspread / float64(samples)
+	return reached
 }
 
 func (ic *IndependentCascade) Diffuse(seeds set.Set) set.Set {
@@ -78,27 +190,27 @@ func (ic *IndependentCascade) Diffuse(seeds set.Set) set.Set {
 
 	for queue.Len() > 0 {
 		node_id := queue.Peek().(util.Node)
-		ic.sampleOutGoingEdges(node_id, queue, active, false, false)
+		ic.sampleOutGoingEdges(node_id, queue, active, ic.random, false, false)
 		queue.Pop()
 	}
 
 	return active
 }
 
-func (ic *IndependentCascade) sampleOutGoingEdges(node util.Node, queue *util.Queue, active set.Set, trial, inv bool) {
+func (ic *IndependentCascade) sampleOutGoingEdges(node util.Node, queue *util.Queue, active set.Set, random *grand.Rand, trial, inv bool) {
 	neighborList := ic.graph.Neighbors(node, inv)
 	if neighborList != nil {
 		for _, edge := range neighborList {
 			act := 0
 			if !active.Contains(edge.Target) {
-				if ic.random.Float64() <= edge.Dist {
+				if random.Float64() <= edge.Dist {
 					active.Add(edge.Target)
 					queue.Push(edge.Target)
 					act = 1
 				}
 
 				if trial {
-					ic.trials = append(ic.trials, util.TrialType{node, edge.Target, act})
+					ic.trials = append(ic.trials, util.TrialType{Source: node, Target: edge.Target, Trial: act})
 				}
 			}
 		}
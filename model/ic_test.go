@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"testing"
+)
+
+// testGraph returns the circulant graph of 50 nodes in testdata, each with 3 out-edges of
+// probability 0.2.
+func testGraph(t *testing.T) *util.Graph {
+	t.Helper()
+	g, err := util.NewGraph("../testdata/circulant.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestIndependentCascadeWorkers(t *testing.T) {
+	g := testGraph(t)
+	seeds := set.NewSet(util.Node(0), util.Node(25))
+	var want util.Spread
+	for i, workers := range []int{1, 8, 3} {
+		config := &util.Config{Seed: 7, Simulations: 1000, Workers: workers}
+		ic := NewIndependentCascade(g, config, 0)
+		got := ic.Sample(set.NewSet(), seeds)
+		if got.Samples != 1000 || got.Mean < 2 {
+			t.Fatalf("workers=%d: implausible spread %v", workers, got)
+		}
+		if i == 0 {
+			want = got
+		} else if got != want {
+			t.Errorf("workers=%d: spread %v, want %v as with 1 worker", workers, got, want)
+		}
+	}
+}
+
+func TestIndependentCascadeConcurrentSample(t *testing.T) {
+	g := testGraph(t)
+	ic := NewIndependentCascade(g, &util.Config{Seed: 7, Simulations: 200, Workers: 2}, 0)
+	seeds := set.NewSet(util.Node(0))
+	done := make(chan util.Spread)
+	for i := 0; i < 4; i++ {
+		go func() { done <- ic.Sample(set.NewSet(), seeds) }()
+	}
+	for i := 0; i < 4; i++ {
+		if s := <-done; s.Samples != 200 {
+			t.Errorf("got %d samples, want 200", s.Samples)
+		}
+	}
+}
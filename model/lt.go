@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"github.com/jtejido/goim/util"
 	"github.com/jtejido/set"
 	"github.com/lucky-se7en/grand"
@@ -16,17 +17,180 @@ type LinearThreshold struct {
 	t      int
 }
 
+func init() {
+	Register(Registration{
+		Name:        LT,
+		Description: "Linear Threshold, a node activates once the weights of its active in-neighbors exceed a uniform random threshold",
+		New: func(graph *util.Graph, config *util.Config, t int) (Model, error) {
+			return NewLinearThreshold(graph, config, t), nil
+		},
+		Options: []util.Option{
+			{Key: "simulations", Description: "Monte Carlo simulations per spread estimate"},
+			{Key: "precision", Description: "target relative half-width of the 95% confidence interval"},
+			{Key: "maxSimulations", Description: "simulation cap when precision is set"},
+		},
+	})
+}
+
 func NewLinearThreshold(graph *util.Graph, config *util.Config, t int) *LinearThreshold {
 	ret := &LinearThreshold{graph: graph, config: config, random: grand.New(source64.NewXoShiRo256StarStar(config.Seed)), src: source64.NewMT19937(config.Seed)}
 	ret.t = t
 	return ret
 }
 
+func (lt *LinearThreshold) Sample(activated, seeds set.Set) util.Spread {
+	spread, _ := lt.SampleContext(context.Background(), activated, seeds)
+	return spread
+}
+
+// SampleContext checks ctx every simulation_block simulations.
+func (lt *LinearThreshold) SampleContext(ctx context.Context, activated, seeds set.Set) (util.Spread, error) {
+	return estimate(ctx, lt.config, func(samples int) util.Moments {
+		var m util.Moments
+		for sample := 0; sample < samples; sample++ {
+			if sample%simulation_block == 0 && ctx.Err() != nil {
+				break
+			}
+
+			m.Add(float64(lt.sample(activated, seeds)))
+		}
+
+		return m
+	})
+}
+
+// SampleWith estimates the spread of seeds and, continuing each simulation with the same thresholds,
+// the spread of seeds together with extra.
+func (lt *LinearThreshold) SampleWith(activated, seeds set.Set, extra util.Node) (float64, float64) {
+	outspread, withExtra, _ := lt.SampleWithContext(context.Background(), activated, seeds, extra)
+	return outspread, withExtra
+}
+
+// SampleWithContext checks ctx every simulation_block simulations.
+func (lt *LinearThreshold) SampleWithContext(ctx context.Context, activated, seeds set.Set, extra util.Node) (float64, float64, error) {
+	var outspread, withExtra float64
+	samples := 0
+	for ; samples < lt.config.Simulations; samples++ {
+		if samples%simulation_block == 0 && ctx.Err() != nil {
+			break
+		}
+
+		c := newLtCascade()
+		for source := range seeds.Iter() {
+			c.activate(source.(util.Node))
+		}
+
+		reached := lt.propagate(activated, c)
+		outspread += float64(reached)
+		if c.activate(extra) {
+			reached += lt.propagate(activated, c)
+		}
+
+		withExtra += float64(reached)
+	}
+	if samples == 0 {
+		return 0, 0, ctx.Err()
+	}
+
+	return outspread / float64(samples), withExtra / float64(samples), ctx.Err()
+}
+
+// sample runs a single cascade and returns the number of newly reached nodes outside activated.
+func (lt *LinearThreshold) sample(activated, seeds set.Set) int {
+	c := newLtCascade()
+	for source := range seeds.Iter() {
+		c.activate(source.(util.Node))
+	}
+
+	return lt.propagate(activated, c)
+}
+
+// ltCascade is the state of a single LT simulation. Each node's threshold is drawn only once an
+// active in-neighbour reaches it.
+type ltCascade struct {
+	queue      *util.Queue
+	active     map[util.Node]struct{}
+	thresholds map[util.Node]float64
+	weights    map[util.Node]float64
+}
+
+func newLtCascade() *ltCascade {
+	return &ltCascade{
+		queue:      util.NewQueue(),
+		active:     make(map[util.Node]struct{}),
+		thresholds: make(map[util.Node]float64),
+		weights:    make(map[util.Node]float64),
+	}
+}
+
+func (c *ltCascade) activate(node util.Node) bool {
+	if _, ok := c.active[node]; ok {
+		return false
+	}
+
+	c.active[node] = struct{}{}
+	c.queue.Push(node)
+	return true
+}
+
+// propagate runs the cascade until no node can be activated and returns the number of reached nodes outside activated.
+func (lt *LinearThreshold) propagate(activated set.Set, c *ltCascade) int {
+	var reached int
+	for c.queue.Len() > 0 {
+		node := c.queue.Pop().(util.Node)
+		if !activated.Contains(node) {
+			reached++
+		}
+
+		for _, edge := range lt.graph.Neighbors(node, false) {
+			if _, ok := c.active[edge.Target]; ok {
+				continue
+			}
+
+			threshold, ok := c.thresholds[edge.Target]
+			if !ok {
+				threshold = lt.random.Float64()
+				c.thresholds[edge.Target] = threshold
+			}
+
+			c.weights[edge.Target] += edge.Dist
+			if c.weights[edge.Target] >= threshold {
+				c.activate(edge.Target)
+			}
+		}
+	}
+
+	return reached
+}
+
+// RRSet walks backwards from root, following at most one live in-edge per node as sampled from
+// the node's in-edge weights, until no edge is sampled or the walk revisits a node.
+func (lt *LinearThreshold) RRSet(root util.Node) []util.Node {
+	rr := []util.Node{root}
+	visited := map[util.Node]struct{}{root: {}}
+	for node := root; ; {
+		index := lt.graph.SampleLivingEdge(node, lt.src)
+		if index == -1 {
+			break
+		}
+
+		node = lt.graph.Neighbors(node, true)[index].Target
+		if _, ok := visited[node]; ok {
+			break
+		}
+
+		visited[node] = struct{}{}
+		rr = append(rr, node)
+	}
+
+	return rr
+}
+
 func (lt *LinearThreshold) Diffuse(seeds set.Set) set.Set {
 	visited := set.NewSet()
 	queue := util.NewQueue()
 	liveEdges := make(map[util.Node][]util.Node)
-	for u := 0; u < lt.graph.Nodes().Len(); u++ {
+	for u := 0; u < lt.graph.NumNodes(); u++ {
 		index := lt.graph.SampleLivingEdge(util.Node(u), lt.src)
 		if index == -1 { // Unconnected node or sample with weights summing to less than 1
 			continue
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"github.com/jtejido/goim/util"
+	"github.com/jtejido/set"
+	"math/rand"
+	"testing"
+)
+
+func TestLinearThresholdRRSets(t *testing.T) {
+	// The in-weights of each node of testGraph sum to 0.6.
+	g := testGraph(t)
+	lt := NewLinearThreshold(g, &util.Config{Seed: 1, Simulations: 20000}, 0)
+	seeds := set.NewSet(util.Node(0), util.Node(25))
+	spread := lt.Sample(set.NewSet(), seeds)
+
+	// A node is reached from seeds as often as its reverse-reachable set holds one of them.
+	const sets = 2000000
+	random := rand.New(rand.NewSource(1))
+	covered := 0
+	for i := 0; i < sets; i++ {
+		for _, u := range lt.RRSet(util.Node(random.Intn(g.NumNodes()))) {
+			if seeds.Contains(u) {
+				covered++
+				break
+			}
+		}
+	}
+	if est := float64(covered) / sets * float64(g.NumNodes()); est < spread.Lower || est > spread.Upper {
+		t.Errorf("RR-set estimate %v outside the Monte Carlo interval [%v, %v]", est, spread.Lower, spread.Upper)
+	}
+}
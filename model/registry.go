@@ -0,0 +1,92 @@
+package model
+
+import (
+	"fmt"
+	"github.com/jtejido/goim/util"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Names of the built-in diffusion models.
+const (
+	IC = "ic"
+	LT = "lt"
+)
+
+// Constructor builds a diffusion model on graph, t is the influence type given by the evaluator.
+type Constructor func(graph *util.Graph, config *util.Config, t int) (Model, error)
+
+// Registration describes a diffusion model selectable by name in the model config keys.
+type Registration struct {
+	Name        string
+	Description string
+	New         Constructor
+	// Config keys the model reads.
+	Options []util.Option
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Registration)
+)
+
+// Register makes a diffusion model available by its name, which is case-insensitive. It panics if
+// the name is empty, already taken or the constructor is nil, so it is meant to be called from init.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := strings.ToLower(r.Name)
+	if name == "" || r.New == nil {
+		panic("model: Register needs a name and a constructor")
+	}
+	if _, dup := registry[name]; dup {
+		panic("model: Register called twice for " + name)
+	}
+
+	r.Name = name
+	registry[name] = r
+}
+
+// Lookup returns the registration of the named model.
+func Lookup(name string) (Registration, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return Registration{}, fmt.Errorf("unknown diffusion model %q, registered: %s", name, strings.Join(names(), ", "))
+	}
+
+	return r, nil
+}
+
+// New builds the named model on graph.
+func New(name string, graph *util.Graph, config *util.Config, t int) (Model, error) {
+	r, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.New(graph, config, t)
+}
+
+// Registered returns every registered model sorted by name.
+func Registered() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ret := make([]Registration, 0, len(registry))
+	for _, name := range names() {
+		ret = append(ret, registry[name])
+	}
+
+	return ret
+}
+
+func names() []string {
+	ret := make([]string, 0, len(registry))
+	for name := range registry {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"github.com/jtejido/goim/util"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"ic", "IC", "lt", "Lt"} {
+		r, err := Lookup(name)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", name, err)
+		} else if r.Name != strings.ToLower(name) {
+			t.Errorf("Lookup(%q).Name = %q", name, r.Name)
+		}
+	}
+
+	if _, err := Lookup("sir"); err == nil || !strings.Contains(err.Error(), "unknown diffusion model") {
+		t.Errorf("Lookup(sir) = %v, want an unknown model error", err)
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	m, err := New("sir", testGraph(t), &util.Config{}, 0)
+	if err == nil || m != nil {
+		t.Errorf("New(sir) = %v, %v, want an error", m, err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	newIC := func(graph *util.Graph, config *util.Config, t int) (Model, error) {
+		return NewIndependentCascade(graph, config, t), nil
+	}
+	Register(Registration{Name: "Test-Model", New: newIC})
+	m, err := New("test-model", testGraph(t), &util.Config{Seed: 1}, 0)
+	if err != nil || m == nil {
+		t.Fatalf("New(test-model) = %v, %v", m, err)
+	}
+
+	found := false
+	prev := ""
+	for _, r := range Registered() {
+		if r.Name < prev {
+			t.Errorf("Registered() not sorted: %q after %q", r.Name, prev)
+		}
+		prev = r.Name
+		found = found || r.Name == "test-model"
+	}
+	if !found {
+		t.Errorf("Registered() misses test-model")
+	}
+
+	for _, r := range []Registration{
+		{Name: "TEST-MODEL", New: newIC}, // taken, whatever the case
+		{Name: "", New: newIC},
+		{Name: "no-constructor"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", r.Name)
+				}
+			}()
+			Register(r)
+		}()
+	}
+}
@@ -0,0 +1,491 @@
+package util
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"unsafe"
+)
+
+// Binary graph files hold a header followed by the sections of a Graph, each little-endian and
+// padded to a multiple of 8 bytes so that they can be used in place from a memory-mapped file:
+//
+//	header        magic, version, flags, nodes n, edges m, label bytes, LT weights l, the CRC-32C
+//	              of each section, checksum
+//	labels        n integer labels, then n+1 offsets into the label bytes and the label bytes unless
+//	              every label is an integer spelled canonically
+//	out           n+1 offsets, m edges of (source, target, probability)
+//	in            n+1 offsets, m reversed edges
+//	LT            n+1 offsets, l weights, l weight heaps
+//
+// The checksum is an FNV-1a hash of the header fields before it.
+const (
+	binary_magic       = "GOIMGRPH"
+	binary_version     = 2
+	binary_header_size = 72
+	binary_sections    = 4
+	binary_ext         = ".goim"
+
+	// Flags of the header.
+	binary_int_labels     = 1
+	binary_spelled_labels = 2 // with binary_int_labels, the labels are also stored as spelled
+)
+
+// Whether sections can be used in place: little-endian 64-bit ints and Edge laid out as 3 words.
+var binary_native = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1 && strconv.IntSize == 64 && unsafe.Sizeof(Edge{}) == 24
+}()
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type binaryHeader struct {
+	Flags      uint32
+	Nodes      uint64
+	Edges      uint64
+	LabelBytes uint64
+	LTWeights  uint64
+	// CRC-32C of the labels, out, in and LT sections.
+	Sections [binary_sections]uint32
+}
+
+func (h *binaryHeader) encode() []byte {
+	b := make([]byte, 0, binary_header_size)
+	b = append(b, binary_magic...)
+	b = binary.LittleEndian.AppendUint32(b, binary_version)
+	b = binary.LittleEndian.AppendUint32(b, h.Flags)
+	for _, v := range [4]uint64{h.Nodes, h.Edges, h.LabelBytes, h.LTWeights} {
+		b = binary.LittleEndian.AppendUint64(b, v)
+	}
+	for _, c := range h.Sections {
+		b = binary.LittleEndian.AppendUint32(b, c)
+	}
+
+	return binary.LittleEndian.AppendUint64(b, headerChecksum(b))
+}
+
+// sectionSizes returns the size in bytes of each section of the file described by h.
+func (h *binaryHeader) sectionSizes() [binary_sections]uint64 {
+	var labels uint64
+	if h.Flags&binary_int_labels != 0 {
+		labels = 8 * h.Nodes
+	}
+	if h.Flags&binary_int_labels == 0 || h.Flags&binary_spelled_labels != 0 {
+		labels += 8*(h.Nodes+1) + (h.LabelBytes+7)/8*8
+	}
+	adjacency := 8 * (h.Nodes + 1 + 3*h.Edges)
+
+	return [binary_sections]uint64{labels, adjacency, adjacency, 8 * (h.Nodes + 1 + 2*h.LTWeights)}
+}
+
+// size returns the size of the file described by h.
+func (h *binaryHeader) size() uint64 {
+	size := uint64(binary_header_size)
+	for _, s := range h.sectionSizes() {
+		size += s
+	}
+
+	return size
+}
+
+func headerChecksum(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+// decodeHeader parses and validates the header at the start of data.
+func decodeHeader(data []byte) (*binaryHeader, error) {
+	if len(data) < binary_header_size || string(data[:len(binary_magic)]) != binary_magic {
+		return nil, fmt.Errorf("not a binary graph file")
+	}
+	if v := binary.LittleEndian.Uint32(data[8:]); v != binary_version {
+		return nil, fmt.Errorf("unsupported binary graph version %d", v)
+	}
+	if binary.LittleEndian.Uint64(data[64:]) != headerChecksum(data[:64]) {
+		return nil, fmt.Errorf("header checksum mismatch")
+	}
+
+	h := &binaryHeader{
+		Flags:      binary.LittleEndian.Uint32(data[12:]),
+		Nodes:      binary.LittleEndian.Uint64(data[16:]),
+		Edges:      binary.LittleEndian.Uint64(data[24:]),
+		LabelBytes: binary.LittleEndian.Uint64(data[32:]),
+		LTWeights:  binary.LittleEndian.Uint64(data[40:]),
+	}
+	for i := range h.Sections {
+		h.Sections[i] = binary.LittleEndian.Uint32(data[48+4*i:])
+	}
+	if h.Flags&^(binary_int_labels|binary_spelled_labels) != 0 || h.Flags == binary_spelled_labels {
+		return nil, fmt.Errorf("unknown flags %#x", h.Flags)
+	}
+	// Bound the counts before sizing the file with them.
+	if h.Nodes > 1<<40 || h.Edges > 1<<40 || h.LabelBytes > 1<<44 || h.LTWeights > 1<<41 {
+		return nil, fmt.Errorf("header out of range")
+	}
+	if size := h.size(); uint64(len(data)) != size {
+		return nil, fmt.Errorf("file is %d bytes, its header describes %d", len(data), size)
+	}
+
+	off := uint64(binary_header_size)
+	for i, size := range h.sectionSizes() {
+		if crc32.Checksum(data[off:off+size], castagnoli) != h.Sections[i] {
+			return nil, fmt.Errorf("%s section checksum mismatch", section_names[i])
+		}
+		off += size
+	}
+
+	return h, nil
+}
+
+var section_names = [binary_sections]string{"labels", "out adjacency", "in adjacency", "LT distributions"}
+
+// IsBinaryGraph reports whether the file at path is a binary graph file, as written by WriteBinary.
+func IsBinaryGraph(path string) bool {
+	if path == stdin_path {
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+	magic := make([]byte, len(binary_magic))
+	_, err = io.ReadFull(f, magic)
+	return err == nil && string(magic) == binary_magic
+}
+
+// WriteBinary writes g to w in the binary graph format, see ReadBinaryGraph. Every probability
+// must be in [0, 1].
+func (g *Graph) WriteBinary(w io.Writer) error {
+	for _, e := range g.out.edges {
+		if !(e.Dist >= 0 && e.Dist <= 1) {
+			return fmt.Errorf("probability %g of edge %s -> %s not in [0, 1]", e.Dist, g.Label(e.Src), g.Label(e.Target))
+		}
+	}
+
+	n := g.NumNodes()
+	h := binaryHeader{Nodes: uint64(n), Edges: uint64(g.NumEdges())}
+	if g.ids != nil {
+		h.Flags |= binary_int_labels
+		if g.labels != nil {
+			h.Flags |= binary_spelled_labels
+		}
+	}
+	for _, label := range g.labels {
+		h.LabelBytes += uint64(len(label))
+	}
+	for _, d := range g.ltDist {
+		h.LTWeights += uint64(d.Len())
+	}
+
+	// The sections are encoded twice, for their checksums in the header and then to w.
+	var crcs [binary_sections]hash.Hash32
+	var sections [binary_sections]io.Writer
+	for i := range crcs {
+		crcs[i] = crc32.New(castagnoli)
+		sections[i] = crcs[i]
+	}
+	g.writeSections(sections, &h)
+	for i, c := range crcs {
+		h.Sections[i] = c.Sum32()
+	}
+
+	bw := bufio.NewWriterSize(w, 1<<20)
+	bw.Write(h.encode())
+	g.writeSections([binary_sections]io.Writer{bw, bw, bw, bw}, &h)
+	return bw.Flush()
+}
+
+// writeSections writes each section of g to its writer, assuming that they do not fail.
+func (g *Graph) writeSections(w [binary_sections]io.Writer, h *binaryHeader) {
+	var buf [8]byte
+	word := func(w io.Writer, v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		w.Write(buf[:])
+	}
+
+	for _, id := range g.ids {
+		word(w[0], uint64(id))
+	}
+	if g.ids == nil || g.labels != nil {
+		var off uint64
+		word(w[0], 0)
+		for _, label := range g.labels {
+			off += uint64(len(label))
+			word(w[0], off)
+		}
+		for _, label := range g.labels {
+			io.WriteString(w[0], label)
+		}
+		w[0].Write(make([]byte, (8-h.LabelBytes%8)%8))
+	}
+
+	for i, a := range [2]*adjacency{&g.out, &g.in} {
+		for _, off := range a.offsets {
+			word(w[1+i], uint64(off))
+		}
+		for _, e := range a.edges {
+			word(w[1+i], uint64(e.Src))
+			word(w[1+i], uint64(e.Target))
+			word(w[1+i], math.Float64bits(e.Dist))
+		}
+	}
+
+	var off int
+	word(w[3], 0)
+	for _, d := range g.ltDist {
+		off += d.Len()
+		word(w[3], uint64(off))
+	}
+	for _, d := range g.ltDist {
+		for _, p := range d.weights {
+			word(w[3], math.Float64bits(p))
+		}
+	}
+	for _, d := range g.ltDist {
+		for _, p := range d.heap {
+			word(w[3], math.Float64bits(p))
+		}
+	}
+}
+
+// ReadBinaryGraph loads the binary graph file at path. With mmap set the file is memory-mapped where
+// the platform allows it and its sections are used in place, read-only, so that loading costs little
+// more than validating them; the mapping is kept for the life of the process. The header and every
+// section are checked against their checksums, then the sections for consistency.
+func ReadBinaryGraph(path string, mmap bool) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := fi.Size()
+	if size < binary_header_size || size != int64(int(size)) {
+		return nil, fmt.Errorf("%s: not a binary graph file", path)
+	}
+
+	var data []byte
+	if mmap {
+		data, err = mapFile(f, int(size))
+	} else {
+		data, err = readFile(f, int(size))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	g, err := decodeGraph(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return g, nil
+}
+
+func readFile(f *os.File, size int) ([]byte, error) {
+	// Backed by words so that sections are aligned.
+	words := make([]uint64, (size+7)/8)
+	data := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(words))), len(words)*8)[:size]
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+// decodeGraph builds the graph stored in data, checking that its sections are consistent.
+func decodeGraph(data []byte) (*Graph, error) {
+	h, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	n, m := int(h.Nodes), int(h.Edges)
+	s := &sections{data: data, off: binary_header_size}
+	g := new(Graph)
+	if h.Flags&binary_int_labels != 0 {
+		g.ids = s.ints(n)
+		for u := 1; u < n; u++ {
+			if g.ids[u-1] >= g.ids[u] {
+				return nil, fmt.Errorf("labels not increasing at node %d", u)
+			}
+		}
+	}
+	if h.Flags&binary_int_labels == 0 || h.Flags&binary_spelled_labels != 0 {
+		offsets := s.ints(n + 1)
+		if err := checkOffsets(offsets, int(h.LabelBytes)); err != nil {
+			return nil, fmt.Errorf("labels: %v", err)
+		}
+
+		labels := s.bytes(int(h.LabelBytes))
+		g.labels = make([]string, n)
+		for u := range g.labels {
+			g.labels[u] = string(labels[offsets[u]:offsets[u+1]])
+			if g.ids != nil {
+				// The spelling of an integer label must read as that integer.
+				if id, err := strconv.Atoi(g.labels[u]); err != nil || id != g.ids[u] {
+					return nil, fmt.Errorf("label %q of node %d is not %d", g.labels[u], u, g.ids[u])
+				}
+			} else if u > 0 && g.labels[u-1] >= g.labels[u] {
+				return nil, fmt.Errorf("labels not increasing at node %d", u)
+			}
+		}
+	}
+
+	for _, a := range [2]*adjacency{&g.out, &g.in} {
+		a.offsets = s.ints(n + 1)
+		a.edges = s.edges(m)
+		if err := checkOffsets(a.offsets, m); err != nil {
+			return nil, fmt.Errorf("adjacency: %v", err)
+		}
+		for u := 0; u < n; u++ {
+			for _, e := range a.edges[a.offsets[u]:a.offsets[u+1]] {
+				if e.Src != Node(u) || e.Target < 0 || int(e.Target) >= n {
+					return nil, fmt.Errorf("adjacency: edge %d -> %d misplaced at node %d", e.Src, e.Target, u)
+				}
+				if !(e.Dist >= 0 && e.Dist <= 1) {
+					return nil, fmt.Errorf("adjacency: probability %g of edge %d -> %d not in [0, 1]", e.Dist, e.Src, e.Target)
+				}
+			}
+		}
+	}
+
+	offsets := s.ints(n + 1)
+	if err := checkOffsets(offsets, int(h.LTWeights)); err != nil {
+		return nil, fmt.Errorf("LT distributions: %v", err)
+	}
+
+	weights := s.floats(int(h.LTWeights))
+	heap := s.floats(int(h.LTWeights))
+	g.ltDist = make([]Weighted, n)
+	for u := range g.ltDist {
+		// One weight per in-edge and one for sampling none, or nothing without in-edges.
+		i, j := offsets[u], offsets[u+1]
+		if indeg := g.in.offsets[u+1] - g.in.offsets[u]; (indeg > 0 || j > i) && j-i != indeg+1 {
+			return nil, fmt.Errorf("LT distributions: %d weights at node %d of in-degree %d", j-i, u, indeg)
+		}
+		for _, p := range weights[i:j] {
+			if !(p >= 0 && p <= 1) {
+				return nil, fmt.Errorf("LT distributions: weight %g at node %d not in [0, 1]", p, u)
+			}
+		}
+		if i < j {
+			g.ltDist[u] = Weighted{weights: weights[i:j:j], heap: heap[i:j:j]}
+		}
+	}
+
+	return g, nil
+}
+
+// checkOffsets reports whether offsets start at 0, never decrease and end at total.
+func checkOffsets(offsets []int, total int) error {
+	if offsets[0] != 0 || offsets[len(offsets)-1] != total {
+		return fmt.Errorf("offsets span %d..%d, not 0..%d", offsets[0], offsets[len(offsets)-1], total)
+	}
+	for i := 1; i < len(offsets); i++ {
+		if offsets[i] < offsets[i-1] {
+			return fmt.Errorf("offsets decrease at %d", i)
+		}
+	}
+
+	return nil
+}
+
+// sections reads the consecutive sections of a binary graph file, whose sizes decodeHeader checked.
+// They are used in place when the platform layout matches the file and data is aligned, decoded
+// otherwise.
+type sections struct {
+	data []byte
+	off  int
+}
+
+func (s *sections) next(size int) []byte {
+	b := s.data[s.off : s.off+size]
+	s.off += (size + 7) / 8 * 8
+	return b
+}
+
+// inPlace reports whether b can be viewed as a slice of words.
+func inPlace(b []byte) bool {
+	return binary_native && len(b) > 0 && uintptr(unsafe.Pointer(&b[0]))%8 == 0
+}
+
+func (s *sections) bytes(k int) []byte {
+	return s.next(k)
+}
+
+func (s *sections) ints(k int) []int {
+	b := s.next(8 * k)
+	if inPlace(b) {
+		return unsafe.Slice((*int)(unsafe.Pointer(&b[0])), k)
+	}
+
+	v := make([]int, k)
+	for i := range v {
+		v[i] = int(int64(binary.LittleEndian.Uint64(b[8*i:])))
+	}
+	return v
+}
+
+func (s *sections) floats(k int) []float64 {
+	b := s.next(8 * k)
+	if inPlace(b) {
+		return unsafe.Slice((*float64)(unsafe.Pointer(&b[0])), k)
+	}
+
+	v := make([]float64, k)
+	for i := range v {
+		v[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	return v
+}
+
+func (s *sections) edges(k int) []Edge {
+	b := s.next(24 * k)
+	if inPlace(b) {
+		return unsafe.Slice((*Edge)(unsafe.Pointer(&b[0])), k)
+	}
+
+	v := make([]Edge, k)
+	for i := range v {
+		w := b[24*i:]
+		v[i] = Edge{
+			Src:    Node(int64(binary.LittleEndian.Uint64(w))),
+			Target: Node(int64(binary.LittleEndian.Uint64(w[8:]))),
+			Dist:   math.Float64frombits(binary.LittleEndian.Uint64(w[16:])),
+		}
+	}
+	return v
+}
+
+// reweight returns a copy of g with the probabilities given by weighting.
+func (g *Graph) reweight(weighting Weighting, seed int64) *Graph {
+	names := make([]string, g.NumNodes())
+	for u := range names {
+		names[u] = g.Label(Node(u))
+	}
+
+	src := make([]int, 0, g.NumEdges())
+	dst := make([]int, 0, g.NumEdges())
+	dist := make([]float64, 0, g.NumEdges())
+	for _, e := range g.out.edges {
+		src = append(src, int(e.Src))
+		dst = append(dst, int(e.Target))
+		dist = append(dist, e.Dist)
+	}
+
+	return newGraph(names, src, dst, dist, weighting, seed)
+}
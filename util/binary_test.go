@@ -0,0 +1,182 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeBinaryGraph writes g in the binary format to a file of dir and returns its path.
+func writeBinaryGraph(t *testing.T, dir string, g *Graph) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := g.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "graph"+binary_ext)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for name, text := range map[string]string{
+		"integer labels": "1000 3 0.5\n3 10 0.25\n10 1000 0.125\n3 1000 0.5\n-4 3 1\n",
+		"spelled labels": "1000 003 0.5\n003 +10 0.25\n10 1000 0.125\n3 1000 0.5\n-04 3 1\n",
+		"string labels":  "bob alice 0.5\nalice carol 0.25\ncarol bob 0.125\nalice bob 0.5\n10 bob 1\n",
+		"no edges":       "",
+	} {
+		g := readTestGraph(t, text)
+		path := writeBinaryGraph(t, t.TempDir(), g)
+		if !IsBinaryGraph(path) {
+			t.Errorf("%s: IsBinaryGraph is false", name)
+		}
+
+		for _, mmap := range []bool{false, true} {
+			b, err := ReadBinaryGraph(path, mmap)
+			if err != nil {
+				t.Errorf("%s, mmap %t: %v", name, mmap, err)
+				continue
+			}
+			if !b.SameNodes(g) || (b.ids == nil) != (g.ids == nil) {
+				t.Errorf("%s, mmap %t: other nodes", name, mmap)
+			}
+			if edges(b) != edges(g) || b.Checksum() != g.Checksum() {
+				t.Errorf("%s, mmap %t: edges\n%s, want\n%s", name, mmap, edges(b), edges(g))
+			}
+			for u := 0; u < g.NumNodes(); u++ {
+				if in, want := b.Neighbors(Node(u), true), g.Neighbors(Node(u), true); !reflect.DeepEqual(in, want) {
+					t.Errorf("%s, mmap %t: in-edges of %s are %v, want %v", name, mmap, g.Label(Node(u)), in, want)
+				}
+				if d, want := b.ltDist[u], g.ltDist[u]; !reflect.DeepEqual(d.weights, want.weights) || !reflect.DeepEqual(d.heap, want.heap) {
+					t.Errorf("%s, mmap %t: LT distribution of %s is %v, want %v", name, mmap, g.Label(Node(u)), d, want)
+				}
+			}
+		}
+	}
+}
+
+// reseal updates the section checksums and the header checksum of the binary graph file b.
+func reseal(b []byte) []byte {
+	h := binaryHeader{
+		Flags:      binary.LittleEndian.Uint32(b[12:]),
+		Nodes:      binary.LittleEndian.Uint64(b[16:]),
+		Edges:      binary.LittleEndian.Uint64(b[24:]),
+		LabelBytes: binary.LittleEndian.Uint64(b[32:]),
+		LTWeights:  binary.LittleEndian.Uint64(b[40:]),
+	}
+	off := uint64(binary_header_size)
+	for i, size := range h.sectionSizes() {
+		h.Sections[i] = crc32.Checksum(b[off:off+size], castagnoli)
+		off += size
+	}
+	copy(b, h.encode())
+	return b
+}
+
+func TestBinaryCorrupt(t *testing.T) {
+	g := readTestGraph(t, "1 2 0.5\n2 3 0.25\n3 1 0.125\n")
+	var buf bytes.Buffer
+	if err := g.WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	n, m := g.NumNodes(), g.NumEdges()
+	corrupt := func(f func(b []byte)) []byte {
+		b := append([]byte{}, data...)
+		f(b)
+		return b
+	}
+	// forge changes the sections like corrupt, then updates their checksums.
+	forge := func(f func(b []byte)) []byte {
+		return reseal(corrupt(f))
+	}
+	// Offsets of word i of the out adjacency and of the LT distributions, after the header and the
+	// n integer labels.
+	out := func(i int) int { return binary_header_size + 8*(n+i) }
+	lt := func(i int) int { return out(2*(n+1+3*m) + i) }
+	put := func(b []byte, off int, v uint64) { binary.LittleEndian.PutUint64(b[off:], v) }
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"magic", corrupt(func(b []byte) { b[0] = 'X' }), "not a binary graph file"},
+		{"short", data[:binary_header_size-1], "not a binary graph file"},
+		{"header", corrupt(func(b []byte) { b[20] ^= 1 }), "header checksum mismatch"},
+		{"section checksum", corrupt(func(b []byte) { b[50] ^= 1 }), "header checksum mismatch"},
+		{"checksum", corrupt(func(b []byte) { b[binary_header_size-1] ^= 1 }), "header checksum mismatch"},
+		{"truncated", data[:len(data)-8], "its header describes"},
+		{"extended", append(append([]byte{}, data...), make([]byte, 8)...), "its header describes"},
+		{"label bytes", corrupt(func(b []byte) { b[binary_header_size] ^= 1 }), "labels section checksum mismatch"},
+		{"probability bytes", corrupt(func(b []byte) { b[out(n+1)+16] ^= 1 }), "out adjacency section checksum mismatch"},
+		{"LT bytes", corrupt(func(b []byte) { b[len(b)-1] ^= 1 }), "LT distributions section checksum mismatch"},
+		{"labels", forge(func(b []byte) { put(b, binary_header_size, 5) }), "labels not increasing"},
+		{"offsets", forge(func(b []byte) { put(b, out(1), 3); put(b, out(2), 1) }), "offsets decrease"},
+		{"span", forge(func(b []byte) { put(b, out(n), 2) }), "offsets span"},
+		{"target", forge(func(b []byte) { put(b, out(n+1)+8, uint64(n)) }), "misplaced"},
+		{"probability", forge(func(b []byte) { put(b, out(n+1)+16, math.Float64bits(1.5)) }), "not in [0, 1]"},
+		{"NaN probability", forge(func(b []byte) { put(b, out(n+1)+16, math.Float64bits(math.NaN())) }), "not in [0, 1]"},
+		{"LT length", forge(func(b []byte) { put(b, lt(1), 1) }), "1 weights at node 0 of in-degree 1"},
+		{"LT weight", forge(func(b []byte) { put(b, lt(n+1), math.Float64bits(-0.5)) }), "weight -0.5 at node 0"},
+	} {
+		path := filepath.Join(dir, tt.name+binary_ext)
+		if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for _, mmap := range []bool{false, true} {
+			if _, err := ReadBinaryGraph(path, mmap); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s, mmap %t: %v, want an error with %q", tt.name, mmap, err, tt.err)
+			}
+		}
+	}
+}
+
+func TestBinarySpelledLabels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := readTestGraph(t, "07 8 0.5\n").WriteBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The labels section holds the ids 7 and 8, 3 offsets, then "078".
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name string
+		edit func(b []byte)
+		err  string
+	}{
+		{"spelling", func(b []byte) { b[binary_header_size+40] = '1' }, `label "17" of node 0 is not 7`},
+		{"flags", func(b []byte) { b[12] = binary_spelled_labels }, "unknown flags 0x2"},
+		{"unknown flag", func(b []byte) { b[12] |= 4 }, "unknown flags 0x7"},
+	} {
+		b := append([]byte{}, buf.Bytes()...)
+		tt.edit(b)
+		path := filepath.Join(dir, tt.name+binary_ext)
+		if err := os.WriteFile(path, reseal(b), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadBinaryGraph(path, false); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: %v, want an error with %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestWriteBinaryProbabilities(t *testing.T) {
+	g := readTestGraph(t, "1 2 0.5\n2 3 1.5\n")
+	if err := g.WriteBinary(io.Discard); err == nil || !strings.Contains(err.Error(), "edge 2 -> 3") {
+		t.Errorf("WriteBinary = %v, want an error on edge 2 -> 3", err)
+	}
+}
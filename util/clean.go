@@ -0,0 +1,333 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CleanOptions configures Clean.
+type CleanOptions struct {
+	// Add the reverse of every edge, for graph files listing undirected edges once.
+	Undirected bool
+	// Keep only the largest weakly ("wcc") or strongly ("scc") connected component. When empty or
+	// "none", every node with an edge is kept, while nodes left isolated once self-loops are removed
+	// are dropped and counted in CleanStats.Nodes.
+	Component string
+	// How the probabilities of duplicate edges are merged: "max" (default), "min", "first", "mean",
+	// "sum" (capped at 1) or "noisyor" (1 - Π(1 - p), as if each copy was tried independently).
+	Merge string
+	// Relabel the nodes 0..n-1 in their order in the graph.
+	Compact bool
+	// Weighting spec applied to the cleaned graph, see ParseWeighting, and the seed of random ones.
+	Weighting string
+	Seed      int64
+}
+
+// CleanStats counts what Clean removed.
+type CleanStats struct {
+	SelfLoops  int
+	Duplicates int
+	// Nodes and edges outside the kept component.
+	Nodes int
+	Edges int
+}
+
+type cleanEdge struct {
+	u, v Node
+	p    float64
+}
+
+// Clean returns a copy of g without self-loops nor duplicate edges, restricted to its largest
+// connected component if asked to.
+func Clean(g *Graph, opts CleanOptions) (*Graph, CleanStats, error) {
+	var stats CleanStats
+	merge, err := mergePolicy(opts.Merge)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var weighting Weighting
+	if opts.Weighting != "" {
+		if weighting, err = ParseWeighting(opts.Weighting); err != nil {
+			return nil, stats, err
+		}
+	}
+
+	edges := make([]cleanEdge, 0, g.NumEdges())
+	for _, e := range g.out.edges {
+		if e.Src == e.Target {
+			stats.SelfLoops++
+			continue
+		}
+
+		edges = append(edges, cleanEdge{e.Src, e.Target, e.Dist})
+	}
+	if opts.Undirected {
+		for _, e := range edges[:len(edges):len(edges)] {
+			edges = append(edges, cleanEdge{e.v, e.u, e.p})
+		}
+	}
+
+	// Duplicates are merged in the order they appear in the graph.
+	sort.SliceStable(edges, func(i, j int) bool {
+		return edges[i].u < edges[j].u || (edges[i].u == edges[j].u && edges[i].v < edges[j].v)
+	})
+	m := 0
+	for i := 0; i < len(edges); {
+		j := i + 1
+		for j < len(edges) && edges[j].u == edges[i].u && edges[j].v == edges[i].v {
+			j++
+		}
+
+		edges[m] = edges[i]
+		if j-i > 1 {
+			ps := make([]float64, j-i)
+			for k := range ps {
+				ps[k] = edges[i+k].p
+			}
+			stats.Duplicates += j - i - 1
+			edges[m].p = merge(ps)
+		}
+		m++
+		i = j
+	}
+	edges = edges[:m]
+
+	n := g.NumNodes()
+	keep := make([]bool, n)
+	switch strings.ToLower(opts.Component) {
+	case "", "none":
+		for _, e := range edges {
+			keep[e.u], keep[e.v] = true, true
+		}
+	case "wcc":
+		keep = largestWCC(n, edges)
+	case "scc":
+		keep = largestSCC(n, edges)
+	default:
+		return nil, stats, fmt.Errorf("unknown component %q, one of wcc, scc or none", opts.Component)
+	}
+
+	index := make([]int, n)
+	names := make([]string, 0)
+	for u := 0; u < n; u++ {
+		index[u] = -1
+		if !keep[u] {
+			stats.Nodes++
+			continue
+		}
+
+		index[u] = len(names)
+		if opts.Compact {
+			names = append(names, strconv.Itoa(len(names)))
+		} else {
+			names = append(names, g.Label(Node(u)))
+		}
+	}
+
+	src := make([]int, 0, len(edges))
+	dst := make([]int, 0, len(edges))
+	dist := make([]float64, 0, len(edges))
+	for _, e := range edges {
+		if index[e.u] < 0 || index[e.v] < 0 {
+			stats.Edges++
+			continue
+		}
+
+		src = append(src, index[e.u])
+		dst = append(dst, index[e.v])
+		dist = append(dist, e.p)
+	}
+
+	return newGraph(names, src, dst, dist, weighting, opts.Seed), stats, nil
+}
+
+// mergePolicy returns the function merging the probabilities of duplicate edges.
+func mergePolicy(name string) (func(ps []float64) float64, error) {
+	switch strings.ToLower(name) {
+	case "", "max":
+		return func(ps []float64) float64 {
+			max := ps[0]
+			for _, p := range ps {
+				max = math.Max(max, p)
+			}
+			return max
+		}, nil
+	case "min":
+		return func(ps []float64) float64 {
+			min := ps[0]
+			for _, p := range ps {
+				min = math.Min(min, p)
+			}
+			return min
+		}, nil
+	case "first":
+		return func(ps []float64) float64 { return ps[0] }, nil
+	case "mean":
+		return func(ps []float64) float64 { return sum(ps) / float64(len(ps)) }, nil
+	case "sum":
+		return func(ps []float64) float64 { return math.Min(sum(ps), 1) }, nil
+	case "noisyor":
+		return func(ps []float64) float64 {
+			q := 1.
+			for _, p := range ps {
+				q *= 1 - p
+			}
+			return 1 - q
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown merge policy %q, one of max, min, first, mean, sum or noisyor", name)
+}
+
+func sum(ps []float64) (s float64) {
+	for _, p := range ps {
+		s += p
+	}
+	return
+}
+
+// largestWCC marks the nodes of the largest weakly connected component of the graph of n nodes and
+// the given edges, the one with the smallest node on ties.
+func largestWCC(n int, edges []cleanEdge) []bool {
+	parent := make([]int, n)
+	for u := range parent {
+		parent[u] = u
+	}
+	find := func(u int) int {
+		for parent[u] != u {
+			parent[u] = parent[parent[u]]
+			u = parent[u]
+		}
+		return u
+	}
+
+	linked := make([]bool, n)
+	for _, e := range edges {
+		linked[e.u], linked[e.v] = true, true
+		ru, rv := find(int(e.u)), find(int(e.v))
+		if ru < rv {
+			parent[rv] = ru
+		} else if rv < ru {
+			parent[ru] = rv
+		}
+	}
+
+	comp := make([]int, n)
+	for u := 0; u < n; u++ {
+		if linked[u] {
+			comp[u] = find(u)
+		} else {
+			comp[u] = -1
+		}
+	}
+
+	return largest(comp)
+}
+
+// largestSCC marks the nodes of the largest strongly connected component of the graph of n nodes
+// and the given edges, sorted by source, the one with the smallest node on ties. Components are
+// found with an iterative Tarjan's algorithm.
+func largestSCC(n int, edges []cleanEdge) []bool {
+	offsets := make([]int, n+1)
+	for _, e := range edges {
+		offsets[e.u+1]++
+	}
+	for u := 0; u < n; u++ {
+		offsets[u+1] += offsets[u]
+	}
+
+	const unvisited = -1
+	order := make([]int, n)
+	low := make([]int, n)
+	comp := make([]int, n)
+	onStack := make([]bool, n)
+	next := make([]int, n) // next out-edge to explore
+	for u := range order {
+		order[u] = unvisited
+		comp[u] = -1
+	}
+
+	var counter int
+	stack := make([]int, 0)
+	calls := make([]int, 0)
+	for root := 0; root < n; root++ {
+		if order[root] != unvisited || offsets[root] == offsets[root+1] {
+			continue
+		}
+
+		calls = append(calls, root)
+		order[root], low[root] = counter, counter
+		counter++
+		next[root] = offsets[root]
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(calls) > 0 {
+			u := calls[len(calls)-1]
+			if next[u] < offsets[u+1] {
+				v := int(edges[next[u]].v)
+				next[u]++
+				if order[v] == unvisited {
+					order[v], low[v] = counter, counter
+					counter++
+					next[v] = offsets[v]
+					stack = append(stack, v)
+					onStack[v] = true
+					calls = append(calls, v)
+				} else if onStack[v] && order[v] < low[u] {
+					low[u] = order[v]
+				}
+				continue
+			}
+
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				if p := calls[len(calls)-1]; low[u] < low[p] {
+					low[p] = low[u]
+				}
+			}
+			if low[u] == order[u] {
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp[w] = u
+					if w == u {
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return largest(comp)
+}
+
+// largest marks the nodes of the most frequent non-negative component id of comp, the one of the
+// smallest node on ties.
+func largest(comp []int) []bool {
+	size := make(map[int]int)
+	best, bestSize := -1, 0
+	for _, c := range comp {
+		if c < 0 {
+			continue
+		}
+
+		size[c]++
+	}
+	for _, c := range comp {
+		if c >= 0 && size[c] > bestSize {
+			best, bestSize = c, size[c]
+		}
+	}
+
+	keep := make([]bool, len(comp))
+	for u, c := range comp {
+		keep[u] = c >= 0 && c == best
+	}
+
+	return keep
+}
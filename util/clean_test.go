@@ -0,0 +1,147 @@
+package util
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		opts  CleanOptions
+		edges string
+		stats CleanStats
+	}{
+		{
+			name:  "none",
+			text:  "1 2 0.5\n2 3 0.5\n4 5 0.5\n6 6 0.5\n",
+			edges: "1 2 0.5\n2 3 0.5\n4 5 0.5\n",
+			stats: CleanStats{SelfLoops: 1, Nodes: 1},
+		},
+		{
+			name:  "wcc",
+			text:  "1 2 0.5\n3 2 0.5\n4 5 0.5\n6 6 0.5\n",
+			opts:  CleanOptions{Component: "wcc"},
+			edges: "1 2 0.5\n3 2 0.5\n",
+			stats: CleanStats{SelfLoops: 1, Nodes: 3, Edges: 1},
+		},
+		{
+			// 1 -> 2 -> 3 -> 1 outweighs 4 <-> 5, which 3 reaches.
+			name:  "scc",
+			text:  "1 2 0.5\n2 3 0.5\n3 1 0.5\n3 4 0.5\n4 5 0.5\n5 4 0.5\n",
+			opts:  CleanOptions{Component: "SCC"},
+			edges: "1 2 0.5\n2 3 0.5\n3 1 0.5\n",
+			stats: CleanStats{Nodes: 2, Edges: 3},
+		},
+		{
+			name:  "scc of a dag",
+			text:  "1 2 0.5\n2 3 0.5\n",
+			opts:  CleanOptions{Component: "scc"},
+			edges: "",
+			stats: CleanStats{Nodes: 2, Edges: 2},
+		},
+		{
+			name:  "undirected scc",
+			text:  "1 2 0.5\n2 3 0.25\n4 5 0.5\n",
+			opts:  CleanOptions{Component: "scc", Undirected: true},
+			edges: "1 2 0.5\n2 1 0.5\n2 3 0.25\n3 2 0.25\n",
+			stats: CleanStats{Nodes: 2, Edges: 2},
+		},
+		{
+			name:  "compact",
+			text:  "30 10 0.5\n10 20 0.25\n",
+			opts:  CleanOptions{Compact: true},
+			edges: "0 1 0.25\n2 0 0.5\n",
+		},
+		{
+			name:  "compact strings",
+			text:  "b a 0.5\n",
+			opts:  CleanOptions{Compact: true},
+			edges: "1 0 0.5\n",
+		},
+	}
+	for _, tt := range tests {
+		g, stats, err := Clean(readTestGraph(t, tt.text), tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := edges(g); got != tt.edges {
+			t.Errorf("%s: edges\n%s, want\n%s", tt.name, got, tt.edges)
+		}
+		if stats != tt.stats {
+			t.Errorf("%s: stats %+v, want %+v", tt.name, stats, tt.stats)
+		}
+	}
+}
+
+func TestCleanMerge(t *testing.T) {
+	g := readTestGraph(t, "1 2 0.2\n1 2 0.6\n2 1 0.1\n1 2 0.4\n")
+	for _, tt := range []struct {
+		merge string
+		p     float64
+	}{
+		{"", 0.6},
+		{"max", 0.6},
+		{"MIN", 0.2},
+		{"first", 0.2},
+		{"mean", 0.4},
+		{"sum", 1},
+		{"noisyor", 1 - 0.8*0.4*0.6},
+	} {
+		c, stats, err := Clean(g, CleanOptions{Merge: tt.merge})
+		if err != nil {
+			t.Errorf("merge %q: %v", tt.merge, err)
+			continue
+		}
+
+		e := c.Neighbors(0, false)
+		if stats.Duplicates != 2 || len(e) != 1 || math.Abs(e[0].Dist-tt.p) > 1e-12 {
+			t.Errorf("merge %q: %d duplicates, edges %v, want one edge of probability %v", tt.merge, stats.Duplicates, e, tt.p)
+		}
+		if back := c.Neighbors(1, false); len(back) != 1 || back[0].Dist != 0.1 {
+			t.Errorf("merge %q: reverse edge %v changed", tt.merge, back)
+		}
+	}
+
+	// Listing an edge both ways in an undirected file is a duplicate too.
+	c, stats, err := Clean(g, CleanOptions{Merge: "min", Undirected: true})
+	if err != nil || stats.Duplicates != 6 || edges(c) != "1 2 0.1\n2 1 0.1\n" {
+		t.Errorf("undirected: %v, %d duplicates, edges\n%s", err, stats.Duplicates, edges(c))
+	}
+}
+
+func TestCleanKeepsLabels(t *testing.T) {
+	g := readTestGraph(t, "bob alice 0.5\nalice carol 0.5\ndave dave 1\n")
+	c, _, err := Clean(g, CleanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var labels []string
+	for u := 0; u < c.NumNodes(); u++ {
+		labels = append(labels, c.Label(Node(u)))
+	}
+	if want := []string{"alice", "bob", "carol"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels %q, want %q", labels, want)
+	}
+}
+
+func TestCleanErrors(t *testing.T) {
+	g := readTestGraph(t, "1 2 0.5\n")
+	for _, tt := range []struct {
+		opts CleanOptions
+		err  string
+	}{
+		{CleanOptions{Merge: "median"}, "unknown merge policy"},
+		{CleanOptions{Component: "bcc"}, "unknown component"},
+		{CleanOptions{Weighting: "const:abc"}, "not in (0, 1]"},
+	} {
+		if _, _, err := Clean(g, tt.opts); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Clean(%+v) = %v, want an error with %q", tt.opts, err, tt.err)
+		}
+	}
+}
@@ -3,104 +3,65 @@ package util
 import (
 	"fmt"
 	"github.com/BurntSushi/toml"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 )
 
-const (
-	str_celf string = "celf"
-	str_tim  string = "tim"
-	str_md   string = "maxdegree"
-	str_dd   string = "discountdegree"
-	str_pmc  string = "pmc"
-	str_ic   string = "ic"
-	str_lt   string = "lt"
-)
-
-func ToAlgorithm(a string) Algorithm {
-	switch strings.ToLower(a) {
-	case str_celf:
-		return CELF
-	case str_tim:
-		return TIM
-	case str_md:
-		return MAX_DEGREE
-	case str_dd:
-		return DISCOUNT_DEGREE
-	case str_pmc:
-		return PMC
-	default:
-		panic("not supported")
-	}
-}
-
-func ToDiffusionModel(a string) DiffusionModel {
-	switch strings.ToLower(a) {
-	case str_ic:
-		return IC
-	case str_lt:
-		return LT
-	default:
-		panic("not supported")
-	}
-}
-
-type (
-	Algorithm      int
-	DiffusionModel int
-)
-
-const (
-	CELF Algorithm = iota
-	TIM
-	MAX_DEGREE
-	DISCOUNT_DEGREE
-	PMC
-)
-
-const (
-	IC DiffusionModel = iota
-	LT
-)
-
-func (a Algorithm) String() string {
-	switch a {
-	case CELF:
-		return strings.ToUpper(str_celf)
-	case TIM:
-		return strings.ToUpper(str_tim)
-	case MAX_DEGREE:
-		return strings.ToUpper(str_md)
-	case DISCOUNT_DEGREE:
-		return strings.ToUpper(str_dd)
-	case PMC:
-		return strings.ToUpper(str_pmc)
-	default:
-		panic("not supported")
-	}
-}
-
-func (a DiffusionModel) String() string {
-	switch a {
-	case IC:
-		return strings.ToUpper(str_ic)
-	case LT:
-		return strings.ToUpper(str_lt)
-	default:
-		panic("not supported")
-	}
+// Option documents a config key read by a registered algorithm or diffusion model.
+type Option struct {
+	Key         string
+	Description string
 }
 
 // This is the base Config type for the API. Extend as needed.
 type Config struct {
-	OutputDir   string `toml:"outputDir"`
-	GraphPath   string `toml:"graphPath"`
-	Trials      int    `toml:"trials"`
-	Algorithm   string `toml:"algorithm"`
-	Seeds       int    `toml:"seeds"`
-	Model       string `toml:"model"`
-	Simulations int    `toml:"simulations"`
-	Seed        int64  `toml:"seed"`
+	OutputDir string `toml:"outputDir"`
+	GraphPath string `toml:"graphPath"`
+	// Edge weighting replacing the probabilities of the graph file, see ParseWeighting.
+	Weighting string `toml:"weighting"`
+	// Format of the graph file, see ReadOptions.
+	DefaultProbability float64 `toml:"defaultProbability"`
+	Progress           int     `toml:"progress"`
+	Comments           string  `toml:"comments"`
+	HeaderLines        int     `toml:"headerLines"`
+	Delimiter          string  `toml:"delimiter"`
+	Undirected         bool    `toml:"undirected"`
+	Mmap               bool    `toml:"mmap"`
+	Trials             int     `toml:"trials"`
+	Algorithm          string  `toml:"algorithm"`
+	Seeds              int     `toml:"seeds"`
+	Model              string  `toml:"model"`
+	// Model assumed by the seed-selection algorithms, Model when unset.
+	SelectionModel string `toml:"selectionModel"`
+	// Models seeds are scored under, each either a model name or "model:graphPath" to use another
+	// probability file. Defaults to Model.
+	EvaluationModel []string `toml:"evaluationModel"`
+	Simulations     int      `toml:"simulations"`
+	Seed            int64    `toml:"seed"`
+	Epsilon         float64  `toml:"epsilon"`
+	Ell             float64  `toml:"ell"`
+	Delta           float64  `toml:"delta"`
+	TimeLimit       float64  `toml:"timeLimit"`
+	Snapshots       int      `toml:"snapshots"`
+	Workers         int      `toml:"workers"`
+	Precision       float64  `toml:"precision"`
+	MaxSimulations  int      `toml:"maxSimulations"`
+	// Memory in MiB RR-set algorithms may hold, unlimited when unset. MemoryPolicy "truncate" returns
+	// the seeds picked on the RR sets that fit instead of failing.
+	MemoryBudget int    `toml:"memoryBudget"`
+	MemoryPolicy string `toml:"memoryPolicy"`
+	// File TIM saves its RR sets to, and reuses them from on later runs over the same graph.
+	RRSetsFile string `toml:"rrSetsFile"`
+	// Cleaning done by the clean command, see CleanOptions.
+	CleanComponent string `toml:"cleanComponent"`
+	CleanMerge     string `toml:"cleanMerge"`
+	CleanCompact   bool   `toml:"cleanCompact"`
+	// Algorithms run by the bench command, all of them when empty.
+	BenchAlgorithms  []string `toml:"benchAlgorithms"`
+	BenchSimulations int      `toml:"benchSimulations"`
+	BenchFormat      string   `toml:"benchFormat"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -117,10 +78,46 @@ func LoadConfig(filename string) (*Config, error) {
 	return &c, nil
 }
 
+// SelectionModelName returns the name of the diffusion model seed-selection algorithms optimize for.
+func (c *Config) SelectionModelName() string {
+	if c.SelectionModel != "" {
+		return strings.ToLower(c.SelectionModel)
+	}
+
+	return strings.ToLower(c.Model)
+}
+
+// EvaluationModels returns the specs of the models seeds are scored under.
+func (c *Config) EvaluationModels() []string {
+	if len(c.EvaluationModel) > 0 {
+		return c.EvaluationModel
+	}
+
+	return []string{c.Model}
+}
+
+// SplitModelSpec splits an evaluation model spec "model[:graphPath]" into its model name and graph path.
+func SplitModelSpec(spec string) (name, graphPath string) {
+	if i := strings.Index(spec, ":"); i >= 0 {
+		return spec[:i], spec[i+1:]
+	}
+
+	return spec, ""
+}
+
+// NumWorkers returns the number of goroutines parallel work is split across, GOMAXPROCS when unset.
+func (c *Config) NumWorkers() int {
+	if c.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return c.Workers
+}
+
 func (c *Config) LogFileName() (s string) {
 	s += c.OutputDir + "/" // put the log file under the output path
-	s += c.GraphPath[strings.LastIndexAny(c.GraphPath, "/")+1:strings.LastIndexAny(c.GraphPath, ".")] + "_"
-	s += strings.ToLower(ToAlgorithm(c.Algorithm).String()) + "_"
+	s += c.graphName() + "_"
+	s += strings.ToLower(c.Algorithm) + "_"
 	s += fmt.Sprintf("%d", c.Trials) + "_"
 	s += fmt.Sprintf("%d", c.Seeds) + "_"
 	s += fmt.Sprintf("%d", c.Seed) + "_"
@@ -128,6 +125,87 @@ func (c *Config) LogFileName() (s string) {
 	return
 }
 
+// EvaluationFileName is the log file of the evaluate command.
+func (c *Config) EvaluationFileName() (s string) {
+	s += c.OutputDir + "/"
+	s += c.graphName() + "_"
+	s += "evaluate_"
+	s += fmt.Sprintf("%d", c.Seed) + "_"
+	s += makeTimestampStr() + ".log"
+	return
+}
+
+// BenchFileName is the output file of the bench command.
+func (c *Config) BenchFileName() (s string) {
+	s += c.OutputDir + "/"
+	s += c.graphName() + "_"
+	s += "bench_"
+	s += fmt.Sprintf("%d", c.Seeds) + "_"
+	s += fmt.Sprintf("%d", c.Seed) + "_"
+	s += makeTimestampStr()
+	if strings.ToLower(c.BenchFormat) == "json" {
+		return s + ".json"
+	}
+
+	return s + ".csv"
+}
+
+// ReadOptions returns how the graph at GraphPath is read.
+func (c *Config) ReadOptions() *ReadOptions {
+	return &ReadOptions{
+		Weighting:          c.Weighting,
+		Seed:               c.Seed,
+		DefaultProbability: c.DefaultProbability,
+		Comments:           c.Comments,
+		HeaderLines:        c.HeaderLines,
+		Delimiter:          c.Delimiter,
+		Undirected:         c.Undirected,
+		Progress:           c.Progress,
+		Mmap:               c.Mmap,
+	}
+}
+
+// WeightsFileName is the graph file written by the weights command, named after the graph and the
+// weighting as in graphs/hep_IC_0.1.inf.
+func (c *Config) WeightsFileName(w Weighting) (s string) {
+	s += c.OutputDir + "/"
+	s += c.graphName() + "_"
+	s += w.String() + ".inf"
+	return
+}
+
+// CleanOptions returns how the clean command cleans the graph at GraphPath. Reverse edges of
+// undirected graphs are already inserted by ReadOptions.
+func (c *Config) CleanOptions() CleanOptions {
+	return CleanOptions{
+		Component: c.CleanComponent,
+		Merge:     c.CleanMerge,
+		Compact:   c.CleanCompact,
+		Weighting: c.Weighting,
+		Seed:      c.Seed,
+	}
+}
+
+// CleanFileName is the graph file written by the clean command.
+func (c *Config) CleanFileName() (s string) {
+	s += c.OutputDir + "/"
+	s += c.graphName() + "_"
+	s += "clean.inf"
+	return
+}
+
+// BinaryFileName is the binary graph file written by the convert command.
+func (c *Config) BinaryFileName() (s string) {
+	s += c.OutputDir + "/"
+	s += c.graphName() + binary_ext
+	return
+}
+
+// graphName is the base name of GraphPath without its extensions, naming the output files.
+func (c *Config) graphName() string {
+	return filepath.Base(TrimInputExt(c.GraphPath))
+}
+
 func makeTimestamp() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
@@ -2,15 +2,21 @@ package util
 
 import (
 	"bufio"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/jtejido/set"
 	"github.com/lucky-se7en/grand"
+	"hash/fnv"
 	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const separator string = " "
@@ -21,138 +27,470 @@ type Edge struct {
 	Dist   float64
 }
 
+// Graph is a directed influence graph in compressed sparse row form. Nodes are identified by the
+// dense internal indices 0..NumNodes()-1 and labeled as in the graph file. When every label is an
+// integer, indices follow the numeric order of the labels, which need not be contiguous nor start at 0,
+// otherwise they follow the lexical order of the labels.
 type Graph struct {
-	nodes        set.Set
-	neighbors    map[Node][]Edge
-	invNeighbors map[Node][]Edge
-	ltDist       map[Node]Weighted
+	ids []int // integer label of each node, increasing, nil unless every label is an integer
+	// Label of each node as first spelled in the graph file, increasing when ids is nil. It is nil
+	// when ids is set and every label is spelled as its integer.
+	labels []string
+	out    adjacency
+	in     adjacency // edges reversed, Target is the source of the original edge
+	ltDist []Weighted
 }
 
-func NewGraph(graphFilePath string) (g *Graph, err error) {
-	f, err := os.Open(graphFilePath)
+// adjacency holds the edges of node u in edges[offsets[u]:offsets[u+1]].
+type adjacency struct {
+	offsets []int
+	edges   []Edge
+}
+
+// ReadOptions configures how a graph file is read. The zero value reads "u v p_uv" lines separated
+// by whitespace.
+type ReadOptions struct {
+	// Weighting spec, see ParseWeighting. When set, the probability column is optional and ignored.
+	Weighting string
+	// Seed of the random weightings.
+	Seed int64
+	// Probability of the edges of lines without one, which are rejected when unset.
+	DefaultProbability float64
+	// Characters starting comment lines, such as "#%" for SNAP and KONECT files.
+	Comments string
+	// Number of header lines to skip.
+	HeaderLines int
+	// Field delimiter: "tab", "comma" or a single character, whitespace when empty. With a delimiter,
+	// labels may contain spaces and fields are trimmed.
+	Delimiter string
+	// Add the reverse of every edge, with the same probability.
+	Undirected bool
+	// Log the progress every Progress edges read, never when unset.
+	Progress int
+	// Memory-map binary graph files, see ReadBinaryGraph.
+	Mmap bool
+}
+
+// NewGraph reads the graph file at graphFilePath, see OpenInput for compressed files and standard
+// input. Binary graph files are loaded with ReadBinaryGraph, their format options ignored.
+func NewGraph(graphFilePath string, opts *ReadOptions) (*Graph, error) {
+	if IsBinaryGraph(graphFilePath) {
+		return newBinaryGraph(graphFilePath, opts)
+	}
+
+	f, err := OpenInput(graphFilePath)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	defer f.Close()
-	g = new(Graph)
-	g.nodes = set.NewSet()
-	g.neighbors = make(map[Node][]Edge)
-	g.invNeighbors = make(map[Node][]Edge)
-	g.ltDist = make(map[Node]Weighted)
-	br := bufio.NewReader(f)
-	var numEdges int
-	maxNodeId := math.MinInt32
-
 	log.Printf("Reading graph file from %s \n", graphFilePath)
-	for {
-		line, _, err := br.ReadLine()
-		if err != nil {
-			if err == io.EOF {
-				break
+	g, err := ReadGraph(f, opts)
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			perr.File = graphFilePath
+			if graphFilePath == stdin_path {
+				perr.File = "stdin"
 			}
+		}
+		return nil, err
+	}
 
+	logGraph(g)
+	return g, nil
+}
+
+// newBinaryGraph loads the binary graph file at graphFilePath, reweighted if opts sets a weighting.
+func newBinaryGraph(graphFilePath string, opts *ReadOptions) (*Graph, error) {
+	var weighting Weighting
+	if opts.Weighting != "" {
+		var err error
+		if weighting, err = ParseWeighting(opts.Weighting); err != nil {
 			return nil, err
 		}
+	}
 
-		fields := strings.Fields(string(line))
-		if len(fields) < 3 {
-			return nil, fmt.Errorf("Invalid graph file format.")
-		}
-		// each line contains one directed edge: (u, v, p_uv)
-		u, err := strconv.Atoi(fields[0])
-		if err != nil {
+	log.Printf("Reading binary graph file from %s \n", graphFilePath)
+	g, err := ReadBinaryGraph(graphFilePath, opts.Mmap)
+	if err != nil {
+		return nil, err
+	}
+	if weighting.kind != "" {
+		g = g.reweight(weighting, opts.Seed)
+	}
+
+	logGraph(g)
+	return g, nil
+}
+
+func logGraph(g *Graph) {
+	log.Printf("Number of nodes = %d \n", g.NumNodes())
+	log.Printf("Number of edges = %d \n", g.NumEdges())
+	log.Println("Finished reading graph file!")
+	if len(g.ids) > 0 {
+		log.Printf("Max node id = %d \n", g.ids[len(g.ids)-1])
+	}
+}
+
+// ReadGraph reads an edge list of "u v p_uv" lines from r, where u and v are node labels without
+// whitespace. A nil opts reads the probabilities of the file. Blank lines are skipped, malformed
+// ones fail with a *ParseError.
+func ReadGraph(r io.Reader, opts *ReadOptions) (*Graph, error) {
+	if opts == nil {
+		opts = new(ReadOptions)
+	}
+
+	var weighting Weighting
+	columns := 3
+	if opts.Weighting != "" {
+		var err error
+		if weighting, err = ParseWeighting(opts.Weighting); err != nil {
 			return nil, err
 		}
-		v, err := strconv.Atoi(fields[1])
-		if err != nil {
-			return nil, err
+		columns = 2
+	}
+	if p := opts.DefaultProbability; p != 0 {
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("default probability %g not in (0, 1]", p)
 		}
-		p, err := strconv.ParseFloat(fields[2], 64)
-		if err != nil {
+		columns = 2
+	}
+
+	split, err := splitter(opts.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	var l labeler
+	var src, dst []int
+	var dist []float64
+	progress := opts.Progress
+	br := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
 			return nil, err
 		}
+		if line == "" && err == io.EOF {
+			break
+		}
 
-		// TO-DO. internal ID that always starts with 0.
-		nu := Node(u)
-		nv := Node(v)
-		numEdges++
-		g.addEdge(nu, nv, p)
+		text := strings.TrimSpace(line)
+		if lineNo <= opts.HeaderLines || text == "" {
+			continue
+		}
+		if first, _ := utf8.DecodeRuneInString(text); strings.ContainsRune(opts.Comments, first) {
+			continue
+		}
 
-		if u > maxNodeId {
-			maxNodeId = u
+		fields := split(text)
+		if len(fields) < columns {
+			return nil, &ParseError{Line: lineNo, Err: fmt.Errorf("expected %d fields, got %d", columns, len(fields))}
+		}
+		// each line contains one directed edge: (u, v, p_uv)
+		p := opts.DefaultProbability
+		if len(fields) > 2 && weighting.kind == "" {
+			var perr error
+			if p, perr = strconv.ParseFloat(fields[2], 64); perr != nil {
+				return nil, &ParseError{Line: lineNo, Err: fmt.Errorf("invalid probability %q", fields[2])}
+			}
 		}
 
-		if v > maxNodeId {
-			maxNodeId = v
+		u, v := l.intern(fields[0]), l.intern(fields[1])
+		src = append(src, u)
+		dst = append(dst, v)
+		dist = append(dist, p)
+		if opts.Undirected && u != v {
+			src = append(src, v)
+			dst = append(dst, u)
+			dist = append(dist, p)
+		}
+		if opts.Progress > 0 && len(src) >= progress {
+			log.Printf("Read %d edges, %d nodes \n", len(src), len(l.names))
+			progress += opts.Progress
 		}
 	}
 
-	for u := 0; u < g.Nodes().Len(); u++ {
-		if g.invNeighbors[Node(u)] != nil { // Only reversed edges are interesting
-			neighbours := g.invNeighbors[Node(u)]
-			w := make([]float64, len(neighbours)+1)
-			var total float64
-			for i := 0; i < len(neighbours); i++ {
-				cur_weight := neighbours[Node(i)].Dist
-				total += cur_weight
-				w[i] = cur_weight
-			}
-			if total < 1 { // Weights do not sum to 1, we can sample no edge
-				w[len(neighbours)] = 1 - total
-			}
+	return newGraph(l.names, src, dst, dist, weighting, opts.Seed), nil
+}
+
+// splitter returns the function splitting lines into fields by delimiter, see ReadOptions.
+func splitter(delimiter string) (func(string) []string, error) {
+	switch strings.ToLower(delimiter) {
+	case "", "whitespace":
+		return strings.Fields, nil
+	case "tab":
+		delimiter = "\t"
+	case "comma":
+		delimiter = ","
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return nil, fmt.Errorf("delimiter %q is not a single character", delimiter)
+	}
 
-			g.ltDist[Node(u)] = NewWeighted(w[:len(w)-1])
+	return func(line string) []string {
+		fields := strings.Split(line, delimiter)
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
 		}
+		return fields
+	}, nil
+}
+
+// labeler numbers node labels in order of first appearance.
+type labeler struct {
+	index map[string]int
+	names []string
+}
+
+func (l *labeler) intern(label string) int {
+	if l.index == nil {
+		l.index = make(map[string]int)
 	}
 
-	log.Printf("Number of nodes = %d \n", g.nodes.Len())
-	log.Printf("Number of edges = %d \n", numEdges)
-	log.Println("Finished reading graph file!")
-	log.Printf("Max node id = %d \n", maxNodeId)
-	return g, nil
+	i, ok := l.index[label]
+	if !ok {
+		i = len(l.names)
+		l.index[label] = i
+		l.names = append(l.names, label)
+	}
+
+	return i
 }
 
-func (g *Graph) addEdge(src, tgt Node, dist float64) {
-	g.addNode(src)
-	g.addNode(tgt)
-	if g.neighbors[src] == nil {
-		g.neighbors[src] = make([]Edge, 0)
+// newGraph builds a graph from the edges src[i] -> dst[i] of probability dist[i], given by their
+// index in names, or weighted by weighting if it is set. The edges of each node keep their order
+// in the lists.
+func newGraph(names []string, src, dst []int, dist []float64, weighting Weighting, seed int64) *Graph {
+	g := new(Graph)
+	ids := make([]int, len(names))
+	for i, name := range names {
+		id, err := strconv.Atoi(name)
+		if err != nil {
+			ids = nil
+			break
+		}
+		ids[i] = id
+	}
+
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	less := func(a, b int) bool { return names[order[a]] < names[order[b]] }
+	if ids != nil {
+		less = func(a, b int) bool { return ids[order[a]] < ids[order[b]] }
+	}
+	// Stable, names being in order of first appearance.
+	sort.SliceStable(order, less)
+
+	// Integer labels such as "7" and "07" name the same node, labeled as first spelled.
+	node := make([]Node, len(names))
+	n, merged, example := 0, 0, ""
+	spelled := false
+	for i, j := range order {
+		if i == 0 || less(i-1, i) {
+			if ids != nil {
+				g.ids = append(g.ids, ids[j])
+				spelled = spelled || names[j] != strconv.Itoa(ids[j])
+			}
+			g.labels = append(g.labels, names[j])
+			n++
+		} else if merged++; merged == 1 {
+			example = fmt.Sprintf("%q and %q", names[order[i-1]], names[j])
+		}
+		node[j] = Node(n - 1)
+	}
+	if merged > 0 {
+		log.Printf("Warning: %d integer labels name the same node as another one, such as %s \n", merged, example)
+	}
+	if ids != nil && !spelled {
+		g.labels = nil
+	}
+
+	us := make([]Node, len(src))
+	vs := make([]Node, len(dst))
+	for i := range src {
+		us[i] = node[src[i]]
+		vs[i] = node[dst[i]]
+	}
+	if weighting.kind != "" {
+		weighting.apply(n, us, vs, dist, seed)
 	}
 
-	if g.invNeighbors[tgt] == nil {
-		g.invNeighbors[tgt] = make([]Edge, 0)
+	g.out = newAdjacency(n, us, vs, dist)
+	g.in = newAdjacency(n, vs, us, dist)
+	g.ltDist = make([]Weighted, n)
+	for u := 0; u < n; u++ {
+		neighbours := g.Neighbors(Node(u), true)
+		if len(neighbours) == 0 { // Only reversed edges are interesting
+			continue
+		}
+
+		w := make([]float64, len(neighbours)+1)
+		var total float64
+		for i := 0; i < len(neighbours); i++ {
+			cur_weight := neighbours[i].Dist
+			total += cur_weight
+			w[i] = cur_weight
+		}
+		if total < 1 { // Weights do not sum to 1, we can sample no edge
+			w[len(neighbours)] = 1 - total
+		}
+
+		// The last slot stands for sampling no edge at all.
+		g.ltDist[u] = NewWeighted(w)
 	}
 
-	g.neighbors[src] = append(g.neighbors[src], Edge{src, tgt, dist})
-	g.invNeighbors[tgt] = append(g.invNeighbors[tgt], Edge{tgt, src, dist})
+	return g
 }
 
-func (g *Graph) addNode(n Node) {
-	g.nodes.Add(n)
+// newAdjacency groups the edges us[i] -> vs[i] of n nodes by their first end, with a counting sort
+// that keeps their order.
+func newAdjacency(n int, us, vs []Node, dist []float64) adjacency {
+	a := adjacency{offsets: make([]int, n+1), edges: make([]Edge, len(us))}
+	for _, u := range us {
+		a.offsets[u+1]++
+	}
+	for u := 0; u < n; u++ {
+		a.offsets[u+1] += a.offsets[u]
+	}
+
+	next := append([]int(nil), a.offsets[:n]...)
+	for i, u := range us {
+		a.edges[next[u]] = Edge{u, vs[i], dist[i]}
+		next[u]++
+	}
+
+	return a
 }
 
-func (g *Graph) Nodes() set.Set {
-	return g.nodes
+// NumNodes returns the number of nodes of g, whose internal indices are 0..NumNodes()-1.
+func (g *Graph) NumNodes() int {
+	if g.ids != nil {
+		return len(g.ids)
+	}
+
+	return len(g.labels)
 }
 
 func (g *Graph) NumEdges() int {
-	return len(g.neighbors)
+	return len(g.out.edges)
+}
+
+// Label returns the label of node u in the graph file, as first spelled there.
+func (g *Graph) Label(u Node) string {
+	if g.labels == nil {
+		return strconv.Itoa(g.ids[u])
+	}
+
+	return g.labels[u]
+}
+
+// key returns the label of node u with integer labels spelled canonically, which tells nodes apart
+// whatever their spelling.
+func (g *Graph) key(u Node) string {
+	if g.ids != nil {
+		return strconv.Itoa(g.ids[u])
+	}
+
+	return g.labels[u]
+}
+
+// Lookup returns the node labeled label in the graph file, and whether there is one. Integer labels
+// match regardless of leading zeros and signs.
+func (g *Graph) Lookup(label string) (Node, bool) {
+	if g.ids != nil {
+		id, err := strconv.Atoi(label)
+		if err != nil {
+			return -1, false
+		}
+
+		i := sort.SearchInts(g.ids, id)
+		if i == len(g.ids) || g.ids[i] != id {
+			return -1, false
+		}
+
+		return Node(i), true
+	}
+
+	i := sort.SearchStrings(g.labels, label)
+	if i == len(g.labels) || g.labels[i] != label {
+		return -1, false
+	}
+
+	return Node(i), true
 }
 
+// SameNodes reports whether g and h have the same nodes, and so the same internal indices.
+func (g *Graph) SameNodes(h *Graph) bool {
+	if g.NumNodes() != h.NumNodes() || (g.ids == nil) != (h.ids == nil) {
+		return false
+	}
+	for u := 0; u < g.NumNodes(); u++ {
+		if g.key(Node(u)) != h.key(Node(u)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checksum returns a 64-bit FNV-1a hash of the edges of g by label and their probabilities, taken
+// node by node in increasing order, for telling apart data derived from different graphs. Integer
+// labels are hashed whatever their spelling.
+func (g *Graph) Checksum() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, e := range g.out.edges {
+		for _, u := range [2]Node{e.Src, e.Target} {
+			label := g.key(u)
+			binary.LittleEndian.PutUint64(buf[:], uint64(len(label)))
+			h.Write(buf[:])
+			h.Write([]byte(label))
+		}
+
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(e.Dist))
+		h.Write(buf[:])
+	}
+
+	return h.Sum64()
+}
+
+// Write writes g to w as "u v p_uv" lines, node by node.
+func (g *Graph) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range g.out.edges {
+		bw.WriteString(g.Label(e.Src))
+		bw.WriteByte('\t')
+		bw.WriteString(g.Label(e.Target))
+		bw.WriteByte('\t')
+		bw.WriteString(strconv.FormatFloat(e.Dist, 'g', -1, 64))
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Neighbors returns the out-edges of node, or its in-edges reversed if inv is set. The slice must
+// not be modified.
 func (g *Graph) Neighbors(node Node, inv bool) []Edge {
+	a := &g.out
 	if inv {
-		return g.invNeighbors[node]
+		a = &g.in
 	}
 
-	return g.neighbors[node]
+	return a.edges[a.offsets[node]:a.offsets[node+1]:a.offsets[node+1]]
 }
 
 func (g *Graph) SampleLivingEdge(node Node, src grand.Source) int {
-	if g.invNeighbors[node] != nil {
-		index, ok := g.ltDist[node].Take(src)
+	if g.ltDist[node].Len() > 0 {
+		index, ok := g.ltDist[node].Sample(src)
 		if ok {
-			if index < len(g.invNeighbors[node]) {
+			if index < len(g.Neighbors(node, true)) {
 				return index
 			}
 		}
@@ -160,3 +498,40 @@ func (g *Graph) SampleLivingEdge(node Node, src grand.Source) int {
 
 	return -1
 }
+
+// LoadSeeds reads a seed set from a file of node labels separated by whitespace or commas. Every node
+// must belong to g and the file must list at least one, a node listed twice is a single seed.
+func (g *Graph) LoadSeeds(seedFilePath string) (set.Set, error) {
+	f, err := os.Open(seedFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	seeds := set.NewSet()
+	br := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			u, ok := g.Lookup(field)
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: node %q is not in the graph", seedFilePath, lineNo, field)
+			}
+
+			seeds.Add(u)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if seeds.Len() == 0 {
+		return nil, fmt.Errorf("%s: no seeds", seedFilePath)
+	}
+
+	return seeds, nil
+}
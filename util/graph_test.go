@@ -0,0 +1,299 @@
+package util
+
+import (
+	"bytes"
+	"github.com/jtejido/set"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readTestGraph(t *testing.T, text string) *Graph {
+	t.Helper()
+	g, err := ReadGraph(strings.NewReader(text), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+// edges returns the out-edges of g as "u v p" lines by label, node by node.
+func edges(g *Graph) string {
+	var b strings.Builder
+	if err := g.Write(&b); err != nil {
+		panic(err)
+	}
+
+	return strings.ReplaceAll(b.String(), "\t", " ")
+}
+
+func TestReadGraphLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		labels []string
+		edges  string
+	}{
+		{
+			name:   "sparse integers",
+			text:   "1000 3 0.5\n3 10 0.25\n",
+			labels: []string{"3", "10", "1000"},
+			edges:  "3 10 0.25\n1000 3 0.5\n",
+		},
+		{
+			name:   "negative integers",
+			text:   "5 -2 1\n-2 0 0.5\n",
+			labels: []string{"-2", "0", "5"},
+			edges:  "-2 0 0.5\n5 -2 1\n",
+		},
+		{
+			name:   "numeric order",
+			text:   "9 10 0.1\n10 100 0.2\n",
+			labels: []string{"9", "10", "100"},
+			edges:  "9 10 0.1\n10 100 0.2\n",
+		},
+		{
+			name:   "strings in lexical order",
+			text:   "bob alice 0.5\nalice 10 0.1\n9 bob 0.2\n",
+			labels: []string{"10", "9", "alice", "bob"},
+			edges:  "9 bob 0.2\nalice 10 0.1\nbob alice 0.5\n",
+		},
+		{
+			name:   "spelled integers",
+			text:   "007 3 0.5\n+3 010 0.25\n",
+			labels: []string{"3", "007", "010"},
+			edges:  "3 010 0.25\n007 3 0.5\n",
+		},
+		{
+			name:   "edges keep their order",
+			text:   "1 3 0.1\n1 2 0.2\n1 3 0.3\n",
+			labels: []string{"1", "2", "3"},
+			edges:  "1 3 0.1\n1 2 0.2\n1 3 0.3\n",
+		},
+	}
+	for _, tt := range tests {
+		g := readTestGraph(t, tt.text)
+		var labels []string
+		for u := 0; u < g.NumNodes(); u++ {
+			labels = append(labels, g.Label(Node(u)))
+		}
+		if !reflect.DeepEqual(labels, tt.labels) {
+			t.Errorf("%s: labels %q, want %q", tt.name, labels, tt.labels)
+		}
+		if got := edges(g); got != tt.edges {
+			t.Errorf("%s: edges\n%s, want\n%s", tt.name, got, tt.edges)
+		}
+		for u, label := range tt.labels {
+			if v, ok := g.Lookup(label); !ok || v != Node(u) {
+				t.Errorf("%s: Lookup(%q) = %d, %t, want %d", tt.name, label, v, ok, u)
+			}
+		}
+	}
+}
+
+func TestReadGraphOptions(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		text  string
+		opts  ReadOptions
+		edges string
+	}{
+		{
+			name:  "undirected",
+			text:  "1 2 0.5\n2 3 0.25\n4 4 1\n",
+			opts:  ReadOptions{Undirected: true},
+			edges: "1 2 0.5\n2 1 0.5\n2 3 0.25\n3 2 0.25\n4 4 1\n",
+		},
+		{
+			name:  "comments",
+			text:  "# SNAP header\n1 2 0.5\n% KONECT header\n  # indented\n2 3 0.25\n",
+			opts:  ReadOptions{Comments: "#%"},
+			edges: "1 2 0.5\n2 3 0.25\n",
+		},
+		{
+			name:  "header lines",
+			text:  "source target p\n\n1 2 0.5\n",
+			opts:  ReadOptions{HeaderLines: 2},
+			edges: "1 2 0.5\n",
+		},
+		{
+			name:  "tab",
+			text:  "new york\tboston\t0.5\nboston \t new york\t0.25\n",
+			opts:  ReadOptions{Delimiter: "tab"},
+			edges: "boston new york 0.25\nnew york boston 0.5\n",
+		},
+		{
+			name:  "comma",
+			text:  "a,b,0.5\nb, c , 0.25\n",
+			opts:  ReadOptions{Delimiter: "comma"},
+			edges: "a b 0.5\nb c 0.25\n",
+		},
+		{
+			name:  "character",
+			text:  "a;b;0.5\n",
+			opts:  ReadOptions{Delimiter: ";"},
+			edges: "a b 0.5\n",
+		},
+		{
+			name:  "space",
+			text:  "1 2 0.5\n2 3 0.25\n",
+			opts:  ReadOptions{Delimiter: " "},
+			edges: "1 2 0.5\n2 3 0.25\n",
+		},
+		{
+			name:  "whitespace",
+			text:  "1 \t 2\t0.5\n",
+			opts:  ReadOptions{Delimiter: "whitespace"},
+			edges: "1 2 0.5\n",
+		},
+		{
+			name:  "default probability",
+			text:  "1 2\n2 3 0.25\n",
+			opts:  ReadOptions{DefaultProbability: 0.1},
+			edges: "1 2 0.1\n2 3 0.25\n",
+		},
+		{
+			name:  "all options",
+			text:  "from,to\n# comment\n1,2\n2,3,0.25\n",
+			opts:  ReadOptions{HeaderLines: 1, Comments: "#", Delimiter: "comma", DefaultProbability: 0.1, Undirected: true},
+			edges: "1 2 0.1\n2 1 0.1\n2 3 0.25\n3 2 0.25\n",
+		},
+	} {
+		g, err := ReadGraph(strings.NewReader(tt.text), &tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := edges(g); got != tt.edges {
+			t.Errorf("%s: edges\n%s, want\n%s", tt.name, got, tt.edges)
+		}
+	}
+
+	for _, tt := range []struct {
+		opts ReadOptions
+		err  string
+	}{
+		{ReadOptions{Delimiter: "::"}, "not a single character"},
+		{ReadOptions{DefaultProbability: 1.5}, "not in (0, 1]"},
+		{ReadOptions{DefaultProbability: -0.5}, "not in (0, 1]"},
+	} {
+		if _, err := ReadGraph(strings.NewReader("1 2\n"), &tt.opts); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ReadGraph(%+v) = %v, want an error with %q", tt.opts, err, tt.err)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	g := readTestGraph(t, "7 12 0.5\n")
+	for _, tt := range []struct {
+		label string
+		node  Node
+		ok    bool
+	}{
+		{"7", 0, true},
+		{"007", 0, true},
+		{"+12", 1, true},
+		{"8", -1, false},
+		{"x", -1, false},
+		{"", -1, false},
+	} {
+		if node, ok := g.Lookup(tt.label); node != tt.node || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %d, %t, want %d, %t", tt.label, node, ok, tt.node, tt.ok)
+		}
+	}
+
+	g = readTestGraph(t, "a b 0.5\n")
+	if node, ok := g.Lookup("c"); ok || node != -1 {
+		t.Errorf("Lookup(c) = %d, %t on string labels", node, ok)
+	}
+}
+
+func TestReadGraphMergedLabels(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	g := readTestGraph(t, "7 8 0.5\n07 8 0.25\n")
+	if g.NumNodes() != 2 || edges(g) != "7 8 0.5\n7 8 0.25\n" {
+		t.Errorf("merged %d nodes with edges\n%s", g.NumNodes(), edges(g))
+	}
+
+	// The node is labeled as first spelled.
+	if g := readTestGraph(t, "8 07 0.5\n7 8 0.25\n"); edges(g) != "07 8 0.25\n8 07 0.5\n" {
+		t.Errorf("merged nodes with edges\n%s", edges(g))
+	}
+	if !strings.Contains(buf.String(), `"07" and "7"`) && !strings.Contains(buf.String(), `"7" and "07"`) {
+		t.Errorf("no warning on merged labels, logged %q", buf.String())
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	base := readTestGraph(t, "1 2 0.5\n2 3 0.25\n3 1 1\n")
+	for _, tt := range []struct {
+		name  string
+		text  string
+		same  bool
+		nodes bool
+	}{
+		{"same file", "1 2 0.5\n2 3 0.25\n3 1 1\n", true, true},
+		{"other line order", "3 1 1\n1 2 0.5\n2 3 0.25\n", true, true},
+		{"leading zeros", "01 2 0.5\n2 3 0.25\n3 01 1\n", true, true},
+		{"other probability", "1 2 0.5\n2 3 0.5\n3 1 1\n", false, true},
+		{"other edge", "1 2 0.5\n2 3 0.25\n1 3 1\n", false, true},
+		{"other label", "1 2 0.5\n2 4 0.25\n4 1 1\n", false, false},
+		{"string labels", "a b 0.5\nb c 0.25\nc a 1\n", false, false},
+	} {
+		g := readTestGraph(t, tt.text)
+		if same := g.Checksum() == base.Checksum(); same != tt.same {
+			t.Errorf("%s: same checksum %t, want %t", tt.name, same, tt.same)
+		}
+		if nodes := g.SameNodes(base); nodes != tt.nodes {
+			t.Errorf("%s: SameNodes %t, want %t", tt.name, nodes, tt.nodes)
+		}
+	}
+
+	// Integer and string labels that read the same are different nodes.
+	ints := readTestGraph(t, "1 2 0.5\n")
+	strs := readTestGraph(t, "1 x 0.5\n")
+	if ints.SameNodes(strs) || strs.SameNodes(ints) {
+		t.Error("integer and string labels have the same nodes")
+	}
+}
+
+func TestLoadSeeds(t *testing.T) {
+	g := readTestGraph(t, "1 2 0.5\n2 3 0.25\n")
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name  string
+		text  string
+		seeds []Node
+		err   string
+	}{
+		{"separators", "1, 3\n", []Node{0, 2}, ""},
+		{"duplicates", "1 1\n3,01\n", []Node{0, 2}, ""},
+		{"unknown label", "1\n4\n", nil, `:2: node "4" is not in the graph`},
+		{"empty", "", nil, "no seeds"},
+		{"blank", "\n , \n", nil, "no seeds"},
+	} {
+		path := filepath.Join(dir, tt.name+".txt")
+		os.WriteFile(path, []byte(tt.text), 0o644)
+		seeds, err := g.LoadSeeds(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: LoadSeeds = %v, %v, want an error with %q", tt.name, seeds, err, tt.err)
+			}
+			continue
+		}
+		want := set.NewSet()
+		for _, u := range tt.seeds {
+			want.Add(u)
+		}
+		if err != nil || !seeds.Equal(want) {
+			t.Errorf("%s: LoadSeeds = %v, %v, want %v", tt.name, seeds, err, tt.seeds)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Standard input is read in place of a file named "-".
+const stdin_path = "-"
+
+// Magic numbers of the compressed formats, used to recognize compressed standard input.
+var (
+	gzip_magic  = []byte{0x1f, 0x8b}
+	bzip2_magic = []byte("BZh")
+	zstd_magic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseError reports a malformed line of a graph file.
+type ParseError struct {
+	File string // empty when unknown
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// OpenInput opens path for reading, or standard input if path is "-". Files ending in .gz, .bz2 or
+// .zst are decompressed, standard input when it starts with the magic number of one of them.
+func OpenInput(path string) (io.ReadCloser, error) {
+	var f *os.File
+	if path == stdin_path {
+		f = os.Stdin
+	} else {
+		var err error
+		if f, err = os.Open(path); err != nil {
+			return nil, err
+		}
+	}
+
+	br := bufio.NewReaderSize(f, 1<<20)
+	format := strings.ToLower(filepath.Ext(path))
+	if path == stdin_path {
+		format = ""
+		magic, _ := br.Peek(len(zstd_magic))
+		switch {
+		case bytes.HasPrefix(magic, gzip_magic):
+			format = ".gz"
+		case bytes.HasPrefix(magic, bzip2_magic):
+			format = ".bz2"
+		case bytes.HasPrefix(magic, zstd_magic):
+			format = ".zst"
+		}
+	}
+
+	var r io.Reader = br
+	closer := func() error { return closeFile(f) }
+	switch format {
+	case ".gz":
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			closeFile(f)
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		r = zr
+	case ".bz2":
+		r = bzip2.NewReader(br)
+	case ".zst":
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			closeFile(f)
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		r = zr
+		closer = func() error {
+			zr.Close()
+			return closeFile(f)
+		}
+	}
+
+	return readCloser{r, closer}, nil
+}
+
+// TrimInputExt returns path without its compression and format extensions, "stdin" for "-".
+func TrimInputExt(path string) string {
+	if path == stdin_path {
+		return "stdin"
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".bz2", ".zst":
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+	}
+
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+func closeFile(f *os.File) error {
+	if f == os.Stdin {
+		return nil
+	}
+
+	return f.Close()
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error {
+	return rc.close()
+}
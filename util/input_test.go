@@ -0,0 +1,145 @@
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const input_text = "1 2 0.5\n2 3 0.25\n"
+
+// compressed returns input_text in each format OpenInput reads, by file extension.
+func compressed(t *testing.T) map[string][]byte {
+	t.Helper()
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(input_text))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+
+	return map[string][]byte{
+		".txt": []byte(input_text),
+		".gz":  gz.Bytes(),
+		// The standard library only decompresses bzip2, this is input_text compressed by bzip2 -9.
+		".bz2": {
+			66, 90, 104, 57, 49, 65, 89, 38, 83, 89, 147, 213, 154, 98, 0, 0, 5, 88, 0, 0, 16, 64, 1, 122, 0,
+			32, 0, 33, 166, 65, 234, 16, 192, 129, 0, 197, 203, 155, 38, 79, 23, 114, 69, 56, 80, 144, 147,
+			213, 154, 98,
+		},
+		".zst": enc.EncodeAll([]byte(input_text), nil),
+	}
+}
+
+func readInput(t *testing.T, path string) string {
+	t.Helper()
+	r, err := OpenInput(path)
+	if err != nil {
+		t.Fatalf("OpenInput(%s): %v", path, err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+
+	return string(b)
+}
+
+func TestOpenInput(t *testing.T) {
+	dir := t.TempDir()
+	for ext, data := range compressed(t) {
+		path := filepath.Join(dir, "graph"+ext)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := readInput(t, path); got != input_text {
+			t.Errorf("%s: read %q, want %q", ext, got, input_text)
+		}
+	}
+
+	// A file is decompressed by its extension, not its contents.
+	path := filepath.Join(dir, "plain.gz")
+	os.WriteFile(path, []byte(input_text), 0o644)
+	if _, err := OpenInput(path); err == nil {
+		t.Errorf("OpenInput(%s) of an uncompressed file did not fail", path)
+	}
+	if _, err := OpenInput(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("OpenInput of a missing file did not fail")
+	}
+}
+
+func TestOpenInputStdin(t *testing.T) {
+	dir := t.TempDir()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	for ext, data := range compressed(t) {
+		f, err := os.Create(filepath.Join(dir, "stdin"+ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+		f.Seek(0, io.SeekStart)
+
+		os.Stdin = f
+		if got := readInput(t, stdin_path); got != input_text {
+			t.Errorf("%s on standard input: read %q, want %q", ext, got, input_text)
+		}
+		f.Close()
+	}
+}
+
+func TestTrimInputExt(t *testing.T) {
+	for path, want := range map[string]string{
+		"-":                "stdin",
+		"data/hep.txt":     "data/hep",
+		"data/hep.txt.gz":  "data/hep",
+		"data/hep.TXT.ZST": "data/hep",
+		"data/hep.bz2":     "data/hep",
+		"data/hep":         "data/hep",
+	} {
+		if got := TrimInputExt(path); got != want {
+			t.Errorf("TrimInputExt(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		opts *ReadOptions
+		line int
+		err  string
+	}{
+		{"1 2 0.5\n2 3\n", nil, 2, "expected 3 fields, got 2"},
+		{"1 2 0.5\n\n\n2 3 high\n", nil, 4, `invalid probability "high"`},
+		{"u v p\n# comment\n1 2 x\n", &ReadOptions{HeaderLines: 1, Comments: "#"}, 3, `invalid probability "x"`},
+		{"1,2\n3\n", &ReadOptions{Delimiter: ",", DefaultProbability: 0.1}, 2, "expected 2 fields, got 1"},
+	} {
+		_, err := ReadGraph(strings.NewReader(tt.text), tt.opts)
+		var perr *ParseError
+		if !errors.As(err, &perr) || perr.Line != tt.line || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ReadGraph(%q) = %v, want a parse error on line %d with %q", tt.text, err, tt.line, tt.err)
+		}
+	}
+
+	// Graph files name the file in their errors.
+	path := filepath.Join(t.TempDir(), "bad.txt")
+	os.WriteFile(path, []byte("1 2 0.5\n1\n"), 0o644)
+	_, err := NewGraph(path, nil)
+	if err == nil || err.Error() != path+":2: expected 3 fields, got 1" {
+		t.Errorf("NewGraph(%s) = %v", path, err)
+	}
+}
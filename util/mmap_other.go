@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package util
+
+import "os"
+
+// mapFile reads f whole where memory mapping is not supported.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return readFile(f, size)
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package util
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the size bytes of f read-only.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
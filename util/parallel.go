@@ -0,0 +1,43 @@
+package util
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Parallel calls f(w, i) for i = 0..n-1 across workers goroutines, w being the index of the worker
+// making the call. It returns once every call has returned. A panic of a call skips the calls not
+// started yet and is raised again in the calling goroutine, so that callers recovering panics, such
+// as the goim package, recover those of their workers too.
+func Parallel(workers, n int, f func(w, i int)) {
+	if workers > n {
+		workers = n
+	}
+
+	var next int64
+	var once sync.Once
+	var panicked bool
+	var value interface{}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicked, value = true, r })
+					atomic.StoreInt64(&next, int64(n))
+				}
+			}()
+
+			for i := int(atomic.AddInt64(&next, 1) - 1); i < n; i = int(atomic.AddInt64(&next, 1) - 1) {
+				f(w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if panicked {
+		panic(value)
+	}
+}
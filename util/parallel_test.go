@@ -0,0 +1,35 @@
+package util
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestParallel(t *testing.T) {
+	for _, tt := range []struct{ workers, n int }{{1, 10}, {4, 100}, {8, 3}, {3, 0}} {
+		calls := make([]int32, tt.n)
+		Parallel(tt.workers, tt.n, func(_, i int) {
+			atomic.AddInt32(&calls[i], 1)
+		})
+		for i, c := range calls {
+			if c != 1 {
+				t.Errorf("workers=%d n=%d: %d called %d times", tt.workers, tt.n, i, c)
+			}
+		}
+	}
+}
+
+func TestParallelPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v, want the panic of the worker", r)
+		}
+	}()
+
+	Parallel(4, 1000, func(w, i int) {
+		if i == 10 {
+			panic("boom")
+		}
+	})
+	t.Errorf("Parallel returned after a panic")
+}
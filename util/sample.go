@@ -82,6 +82,41 @@ func (s Weighted) Take(src grand.Source) (idx int, ok bool) {
 	return idx, true
 }
 
+// Sample returns an index from the Weighted with probability proportional
+// to the weight of the item, leaving the weights unchanged.
+// Sample returns false if all weights are zero.
+func (s Weighted) Sample(src grand.Source) (idx int, ok bool) {
+	if src == nil {
+		panic("Source cannot be nil")
+	}
+	const small = 1e-12
+	if len(s.heap) == 0 || equalWithinAbsOrRel(s.heap[0], 0, small, small) {
+		return -1, false
+	}
+
+	rnd := grand.New(src)
+	r := s.heap[0] * rnd.Float64()
+
+	i := 1
+	for {
+		if r -= s.weights[i-1]; r <= 0 {
+			return i - 1, true // Fall within item i-1.
+		}
+		i <<= 1 // Move to left child.
+		if i > len(s.weights) {
+			// Only reachable through floating point error.
+			return -1, false
+		}
+		if d := s.heap[i-1]; r > d {
+			r -= d
+			i++
+		}
+		if i > len(s.weights) {
+			return -1, false
+		}
+	}
+}
+
 func (s Weighted) Reweight(idx int, w float64) {
 	w, s.weights[idx] = s.weights[idx]-w, w
 	idx++
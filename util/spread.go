@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"math"
+)
+
+// z-score of a two-sided 95% confidence interval under the normal approximation.
+const z95 = 1.959963984540054
+
+// Spread summarizes a Monte Carlo estimate of the influence spread.
+type Spread struct {
+	Mean     float64
+	Variance float64
+	StdErr   float64
+	Lower    float64 // lower end of the 95% confidence interval
+	Upper    float64 // upper end of the 95% confidence interval
+	Samples  int
+}
+
+// Moments accumulates the number, mean and sum of squared deviations from the mean (M2) of
+// simulation outcomes with Welford's online update, which unlike the sum of squares keeps its
+// precision for large spreads and many samples.
+type Moments struct {
+	N    int
+	Mean float64
+	M2   float64
+}
+
+// Add accounts for the outcome x.
+func (m *Moments) Add(x float64) {
+	m.N++
+	d := x - m.Mean
+	m.Mean += d / float64(m.N)
+	m.M2 += d * (x - m.Mean)
+}
+
+// Merge accounts for the outcomes summarized by o, as in Chan et al.'s parallel algorithm.
+func (m *Moments) Merge(o Moments) {
+	if o.N == 0 {
+		return
+	}
+
+	n := m.N + o.N
+	d := o.Mean - m.Mean
+	m.Mean += d * float64(o.N) / float64(n)
+	m.M2 += o.M2 + d*d*float64(m.N)*float64(o.N)/float64(n)
+	m.N = n
+}
+
+// NewSpread builds the estimate from the moments of the simulation outcomes.
+func NewSpread(m Moments) Spread {
+	var s Spread
+	if m.N == 0 {
+		return s
+	}
+
+	n := float64(m.N)
+	s.Samples = m.N
+	s.Mean = m.Mean
+	if m.N > 1 {
+		s.Variance = m.M2 / (n - 1)
+	}
+	s.StdErr = math.Sqrt(s.Variance / n)
+	s.Lower = s.Mean - z95*s.StdErr
+	s.Upper = s.Mean + z95*s.StdErr
+	return s
+}
+
+// RelativeHalfWidth returns the half-width of the confidence interval relative to the mean.
+func (s Spread) RelativeHalfWidth() float64 {
+	if s.Mean == 0 {
+		if s.StdErr == 0 {
+			return 0
+		}
+
+		return math.Inf(1)
+	}
+
+	return z95 * s.StdErr / s.Mean
+}
+
+func (s Spread) String() string {
+	return fmt.Sprintf("%.5f ± %.5f [%.5f, %.5f] (n=%d)", s.Mean, s.StdErr, s.Lower, s.Upper, s.Samples)
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewSpread(t *testing.T) {
+	tests := []struct {
+		name     string
+		xs       []float64
+		mean     float64
+		variance float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single", []float64{5}, 5, 0},
+		{"constant", []float64{3, 3, 3, 3}, 3, 0},
+		{"small", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, 32. / 7},
+		// The sum of squares loses every digit of the variance at this offset.
+		{"offset", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, 1e9 + 10, 30},
+	}
+	for _, tt := range tests {
+		var m Moments
+		for _, x := range tt.xs {
+			m.Add(x)
+		}
+
+		s := NewSpread(m)
+		if s.Samples != len(tt.xs) || math.Abs(s.Mean-tt.mean) > 1e-9*math.Max(1, tt.mean) || math.Abs(s.Variance-tt.variance) > 1e-9 {
+			t.Errorf("%s: got n=%d mean=%v variance=%v, want n=%d mean=%v variance=%v", tt.name, s.Samples, s.Mean, s.Variance, len(tt.xs), tt.mean, tt.variance)
+		}
+		if n := float64(len(tt.xs)); n > 0 {
+			if want := math.Sqrt(tt.variance / n); math.Abs(s.StdErr-want) > 1e-9 {
+				t.Errorf("%s: standard error %v, want %v", tt.name, s.StdErr, want)
+			}
+			if s.Lower > s.Mean || s.Upper < s.Mean || math.Abs((s.Upper-s.Mean)-(s.Mean-s.Lower)) > 1e-6 {
+				t.Errorf("%s: interval [%v, %v] not centered on %v", tt.name, s.Lower, s.Upper, s.Mean)
+			}
+		}
+	}
+}
+
+func TestMomentsMerge(t *testing.T) {
+	xs := make([]float64, 1000)
+	for i := range xs {
+		xs[i] = 1e8 + float64(i%37)*float64(i%11)
+	}
+
+	var all Moments
+	for _, x := range xs {
+		all.Add(x)
+	}
+
+	// Blocks of uneven sizes, including an empty one, merged in order.
+	var merged Moments
+	for _, bounds := range [][2]int{{0, 0}, {0, 64}, {64, 65}, {65, 500}, {500, 1000}} {
+		var block Moments
+		for _, x := range xs[bounds[0]:bounds[1]] {
+			block.Add(x)
+		}
+		merged.Merge(block)
+	}
+
+	if merged.N != all.N || math.Abs(merged.Mean-all.Mean) > 1e-6 || math.Abs(merged.M2-all.M2) > 1e-6*all.M2 {
+		t.Errorf("merged moments %+v, want %+v", merged, all)
+	}
+}
@@ -7,19 +7,52 @@ import (
 	"math"
 )
 
-func LogSeed(round, activated int, roundtime, timetotal float64, seeds set.Set, config *Config, bufferedWriter *bufio.Writer) {
-	seedStr := SeedToLog(round, activated, roundtime, seeds)
+// LogSeed writes a trial's result, whose roundtime is the time spent selecting its seeds in minutes,
+// followed by the estimated spread of its seeds (mean, standard error and 95% confidence interval)
+// under each evaluation model. A non-negative approx is the certified
+// lower bound on the approximation ratio and is appended as an extra column.
+func LogSeed(graph *Graph, round, activated int, roundtime, timetotal float64, seeds set.Set, spreads []Spread, approx float64, config *Config, bufferedWriter *bufio.Writer) {
+	seedStr := SeedToLog(graph, round, activated, roundtime, seeds)
+	for _, spread := range spreads {
+		seedStr += "\t" + fmt.Sprintf("%.5f", spread.Mean)
+		seedStr += "\t" + fmt.Sprintf("%.5f", spread.StdErr)
+		seedStr += "\t" + fmt.Sprintf("%.5f", spread.Lower)
+		seedStr += "\t" + fmt.Sprintf("%.5f", spread.Upper)
+	}
+	if approx >= 0 {
+		seedStr += "\t" + fmt.Sprintf("%.5f", approx)
+	}
+
 	bufferedWriter.WriteString(seedStr + "\n")
 }
 
-func SeedToLog(round, activated int, roundtime float64, seeds set.Set) (s string) {
+// LogSpread writes a seed set with its estimated spread under the named model: mean, standard
+// error, 95% confidence interval and the number of simulations.
+func LogSpread(graph *Graph, model string, seeds set.Set, spread Spread, bufferedWriter *bufio.Writer) {
+	s := model + "\t"
+	s += SeedsToString(graph, seeds) + "\t"
+	s += fmt.Sprintf("%.5f", spread.Mean) + "\t"
+	s += fmt.Sprintf("%.5f", spread.StdErr) + "\t"
+	s += fmt.Sprintf("%.5f", spread.Lower) + "\t"
+	s += fmt.Sprintf("%.5f", spread.Upper) + "\t"
+	s += fmt.Sprintf("%d", spread.Samples)
+	bufferedWriter.WriteString(s + "\n")
+}
+
+func SeedToLog(graph *Graph, round, activated int, roundtime float64, seeds set.Set) (s string) {
 	s += fmt.Sprintf("%d", round) + "\t"
 	s += fmt.Sprintf("%d", activated) + "\t"
 	s += fmt.Sprintf("%.5f", roundtime) + "\t"
+	s += SeedsToString(graph, seeds)
+	return
+}
+
+// SeedsToString formats seeds by their labels in graph.
+func SeedsToString(graph *Graph, seeds set.Set) (s string) {
 	s += "["
 	i := 1
 	for ss := range seeds.Iter() {
-		s += fmt.Sprintf("%d", ss.(Node))
+		s += graph.Label(ss.(Node))
 		if i != seeds.Len() {
 			s += ", "
 		}
@@ -30,6 +63,20 @@ func SeedToLog(round, activated int, roundtime float64, seeds set.Set) (s string
 	return
 }
 
+// DeriveSeed mixes seed with the given stream indices (SplitMix64 finalizer) into a seed for an
+// independent random stream.
+func DeriveSeed(seed int64, streams ...int64) int64 {
+	z := uint64(seed)
+	for _, s := range streams {
+		z += 0x9e3779b97f4a7c15 * uint64(s+1)
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		z ^= z >> 31
+	}
+
+	return int64(z)
+}
+
 func equalWithinAbs(a, b, tol float64) bool {
 	return a == b || math.Abs(a-b) <= tol
 }
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"fmt"
+	"github.com/lucky-se7en/grand"
+	"github.com/lucky-se7en/grand/source64"
+	"strconv"
+	"strings"
+)
+
+// Tri-valency probabilities used when "tv" lists none.
+var tri_valency = []float64{0.1, 0.01, 0.001}
+
+// Weighting assigns influence probabilities to the edges of a graph in place of those of the graph
+// file. See Arora et al., Debunking the Myths of Influence Maximization (SIGMOD 2017).
+type Weighting struct {
+	kind string
+	p    []float64
+}
+
+// ParseWeighting parses a weighting spec:
+//
+//	const:p        every edge gets probability p (IC)
+//	wc             edge (u, v) gets 1/indeg(v), Weighted Cascade
+//	tv[:p1,p2,..]  each edge gets one of the p_i uniformly at random, Tri-valency (0.1, 0.01, 0.001)
+//	uniform        edge (u, v) gets 1/indeg(v), the uniform LT weights
+//	random         the in-edges of each node get random weights summing to 1 (LT)
+func ParseWeighting(spec string) (Weighting, error) {
+	kind, arg := strings.ToLower(spec), ""
+	if i := strings.Index(kind, ":"); i >= 0 {
+		kind, arg = kind[:i], kind[i+1:]
+	}
+
+	w := Weighting{kind: kind}
+	switch kind {
+	case "wc", "uniform", "random":
+		if arg != "" {
+			return w, fmt.Errorf("weighting %s takes no parameter, got %q", kind, spec)
+		}
+	case "const", "tv":
+		if arg == "" && kind == "const" {
+			return w, fmt.Errorf("weighting const needs a probability, as in const:0.01")
+		}
+		for _, f := range strings.Split(arg, ",") {
+			if f == "" {
+				continue
+			}
+
+			p, err := strconv.ParseFloat(f, 64)
+			if err != nil || p <= 0 || p > 1 {
+				return w, fmt.Errorf("weighting %s: probability %q not in (0, 1]", kind, f)
+			}
+			w.p = append(w.p, p)
+		}
+		if kind == "const" && len(w.p) != 1 {
+			return w, fmt.Errorf("weighting const takes a single probability, got %q", spec)
+		}
+		if len(w.p) == 0 {
+			w.p = tri_valency
+		}
+	default:
+		return w, fmt.Errorf("unknown weighting %q, one of const:p, wc, tv[:p1,p2,...], uniform, random", spec)
+	}
+
+	return w, nil
+}
+
+// String returns the suffix of graph files written with w, following the naming of the bundled graphs.
+func (w Weighting) String() string {
+	switch w.kind {
+	case "const":
+		return "IC_" + strconv.FormatFloat(w.p[0], 'g', -1, 64)
+	case "random":
+		return "R"
+	}
+
+	return strings.ToUpper(w.kind)
+}
+
+// apply sets the probability dist[i] of each edge us[i] -> vs[i] of a graph of n nodes. Random
+// weightings draw from a generator seeded by seed, in the order of the edges.
+func (w Weighting) apply(n int, us, vs []Node, dist []float64, seed int64) {
+	rnd := grand.New(source64.NewXoShiRo256StarStar(seed))
+	indeg := make([]float64, n)
+	for i := range vs {
+		indeg[vs[i]]++
+	}
+
+	switch w.kind {
+	case "const":
+		for i := range dist {
+			dist[i] = w.p[0]
+		}
+	case "wc", "uniform":
+		for i := range dist {
+			dist[i] = 1 / indeg[vs[i]]
+		}
+	case "tv":
+		for i := range dist {
+			dist[i] = w.p[rnd.Intn(len(w.p))]
+		}
+	case "random":
+		total := make([]float64, n)
+		for i := range dist {
+			dist[i] = rnd.Float64()
+			total[vs[i]] += dist[i]
+		}
+		for i := range dist {
+			dist[i] /= total[vs[i]]
+		}
+	}
+}
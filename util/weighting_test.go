@@ -0,0 +1,131 @@
+package util
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseWeighting(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		name string
+		err  string
+	}{
+		{"const:0.01", "IC_0.01", ""},
+		{"CONST:1", "IC_1", ""},
+		{"wc", "WC", ""},
+		{"tv", "TV", ""},
+		{"tv:0.5,0.05", "TV", ""},
+		{"uniform", "UNIFORM", ""},
+		{"random", "R", ""},
+		{"const", "", "needs a probability"},
+		{"const:abc", "", `probability "abc" not in (0, 1]`},
+		{"const:0", "", "not in (0, 1]"},
+		{"const:1.5", "", "not in (0, 1]"},
+		{"const:0.1,0.2", "", "single probability"},
+		{"tv:0.1,x", "", `probability "x"`},
+		{"wc:0.1", "", "takes no parameter"},
+		{"lt", "", "unknown weighting"},
+	} {
+		w, err := ParseWeighting(tt.spec)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseWeighting(%q) = %v, want an error with %q", tt.spec, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || w.String() != tt.name {
+			t.Errorf("ParseWeighting(%q) = %v, %v, want %s", tt.spec, w, err, tt.name)
+		}
+	}
+}
+
+// weightedGraph reads a graph where node 3 has 3 in-edges, node 2 has 2 and node 1 has 1.
+func weightedGraph(t *testing.T, spec string, seed int64) *Graph {
+	t.Helper()
+	text := "0 3 0.9\n1 3 0.9\n2 3 0.9\n0 2 0.9\n1 2 0.9\n0 1 0.9\n"
+	g, err := ReadGraph(strings.NewReader(text), &ReadOptions{Weighting: spec, Seed: seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestWeightingInDegree(t *testing.T) {
+	for _, spec := range []string{"wc", "uniform"} {
+		g := weightedGraph(t, spec, 1)
+		for v := 0; v < g.NumNodes(); v++ {
+			in := g.Neighbors(Node(v), true)
+			for _, e := range in {
+				if want := 1 / float64(len(in)); e.Dist != want {
+					t.Errorf("%s: edge %d -> %d has probability %v, want %v", spec, e.Target, v, e.Dist, want)
+				}
+			}
+		}
+	}
+}
+
+func TestWeightingConst(t *testing.T) {
+	g := weightedGraph(t, "const:0.05", 1)
+	for u := 0; u < g.NumNodes(); u++ {
+		for _, e := range g.Neighbors(Node(u), false) {
+			if e.Dist != 0.05 {
+				t.Errorf("edge %d -> %d has probability %v, want 0.05", u, e.Target, e.Dist)
+			}
+		}
+	}
+}
+
+func TestWeightingTriValency(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		p    []float64
+	}{
+		{"tv", tri_valency},
+		{"tv:0.5,0.25", []float64{0.5, 0.25}},
+	} {
+		g := weightedGraph(t, tt.spec, 1)
+		for u := 0; u < g.NumNodes(); u++ {
+			for _, e := range g.Neighbors(Node(u), false) {
+				found := false
+				for _, p := range tt.p {
+					found = found || e.Dist == p
+				}
+				if !found {
+					t.Errorf("%s: edge %d -> %d has probability %v, not one of %v", tt.spec, u, e.Target, e.Dist, tt.p)
+				}
+			}
+		}
+	}
+}
+
+func TestWeightingRandom(t *testing.T) {
+	g := weightedGraph(t, "random", 3)
+	for v := 0; v < g.NumNodes(); v++ {
+		in := g.Neighbors(Node(v), true)
+		if len(in) == 0 {
+			continue
+		}
+
+		var total float64
+		for _, e := range in {
+			if e.Dist <= 0 || e.Dist > 1 {
+				t.Errorf("edge %d -> %d has probability %v", e.Target, v, e.Dist)
+			}
+			total += e.Dist
+		}
+		if math.Abs(total-1) > 1e-12 {
+			t.Errorf("in-edges of node %d sum to %v, want 1", v, total)
+		}
+	}
+
+	// The same seed draws the same weights.
+	if weightedGraph(t, "random", 3).Checksum() != g.Checksum() {
+		t.Error("random weights differ under the same seed")
+	}
+	if weightedGraph(t, "random", 4).Checksum() == g.Checksum() {
+		t.Error("random weights equal under another seed")
+	}
+}